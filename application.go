@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"context"
+	"errors"
+
+	iso "cunicu.li/go-iso7816"
+)
+
+var ErrInvalidAppState = errors.New("invalid application state")
+
+// SetApplicationState enables or disables the OTP application.
+//
+// A disabled application does not emit OTP values via the keyboard
+// interface of the key when its button is touched.
+//
+// The encoding of the command is unconfirmed. It mirrors the language
+// command: P2 0x01 sets the state given by a single data byte while
+// P2 0x00 gets it. No transcript of it has been recorded from a key yet.
+// Whether the state persists across power cycles is unknown.
+func (c *Card) SetApplicationState(state AppState) error {
+	return c.SetApplicationStateContext(context.Background(), state)
+}
+
+// SetApplicationStateContext is like SetApplicationState but honors the cancellation of ctx.
+func (c *Card) SetApplicationStateContext(ctx context.Context, state AppState) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if state != ON && state != OFF {
+		return ErrInvalidAppState
+	}
+
+	_, err := c.send(ctx, &iso.CAPDU{
+		Ins:  insApplication,
+		P1:   0x00,
+		P2:   0x01,
+		Data: []byte{byte(state)},
+	})
+
+	return wrapStatus(err)
+}
+
+// ApplicationState returns whether the OTP application is enabled.
+//
+// Like SetApplicationState, the encoding of the command is unconfirmed.
+func (c *Card) ApplicationState() (AppState, error) {
+	return c.ApplicationStateContext(context.Background())
+}
+
+// ApplicationStateContext is like ApplicationState but honors the cancellation of ctx.
+func (c *Card) ApplicationStateContext(ctx context.Context) (AppState, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	resp, err := c.send(ctx, &iso.CAPDU{
+		Ins: insApplication,
+		P1:  0x00,
+		P2:  0x00,
+	})
+	if err != nil {
+		return OFF, wrapStatus(err)
+	} else if len(resp) < 1 {
+		return OFF, iso.ErrWrongLength
+	}
+
+	if AppState(resp[0]) == OFF {
+		return OFF, nil
+	}
+
+	return ON, nil
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestApplicationState runs against the emulator as no transcript
+// of the application command has been recorded from a key yet.
+func TestApplicationState(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		state, err := c.ApplicationState()
+		require.NoError(err)
+		require.Equal(feitian.ON, state)
+
+		err = c.SetApplicationState(feitian.OFF)
+		require.NoError(err)
+
+		state, err = c.ApplicationState()
+		require.NoError(err)
+		require.Equal(feitian.OFF, state)
+
+		err = c.SetApplicationState(feitian.ON)
+		require.NoError(err)
+
+		state, err = c.ApplicationState()
+		require.NoError(err)
+		require.Equal(feitian.ON, state)
+
+		err = c.SetApplicationState(feitian.AppState(0x02))
+		require.ErrorIs(err, feitian.ErrInvalidAppState)
+	})
+}
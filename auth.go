@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1" //nolint:gosec
+	"errors"
+	"fmt"
+	"io"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+const (
+	codeIterations  = 1000
+	codeKeyLength   = 16
+	challengeLength = 8
+	codeKeyType     = byte(TOTP) | byte(SHA1)
+)
+
+var (
+	ErrWrongCode          = errors.New("wrong access code")
+	ErrCardAuthentication = errors.New("card failed to authenticate")
+	ErrMissingDeviceID    = errors.New("missing device id")
+	ErrNotLocked          = errors.New("applet is not protected by an access code")
+)
+
+// Locked returns true if the applet is protected by an access code
+// which has not yet been validated in the current session.
+//
+// The state is taken from the challenge in the response to Select()
+// as done by ykneo-oath. It has not been verified against a key yet.
+func (c *Card) Locked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.challenge != nil
+}
+
+// SetCode protects the applet with an access code.
+//
+// The commands follow the mutual authentication scheme of
+// Yubico's ykneo-oath applet: A key is derived from the code
+// via PBKDF2 salted with the device ID and later used to
+// answer HMAC challenges during Validate().
+// If the applet is already protected, the current code
+// must be validated first.
+//
+// It is unverified whether the FEITIAN applet implements this scheme.
+// No transcript of these commands has been recorded from a key yet.
+func (c *Card) SetCode(code string) error {
+	return c.SetCodeContext(context.Background(), code)
+}
+
+// SetCodeContext is like SetCode but honors the cancellation of ctx.
+func (c *Card) SetCodeContext(ctx context.Context, code string) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	// The key is salted with the device ID which might have been changed by Reset
+	if err := c.refreshInfo(ctx); err != nil {
+		return err
+	}
+
+	key, err := c.deriveKey(code)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := c.randomChallenge()
+	if err != nil {
+		return err
+	}
+
+	response, err := applet.HMAC(SHA1, key, challenge)
+	if err != nil {
+		return err
+	}
+
+	data, err := tlv.EncodeSimple(
+		tlv.New(tagKey, codeKeyType, key),
+		tlv.New(tagChallenge, challenge),
+		tlv.New(tagResponse, response),
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.send(ctx, &iso.CAPDU{
+		Ins:  insSetCode,
+		P1:   0x00,
+		P2:   0x00,
+		Data: data,
+	}); err != nil {
+		return err
+	}
+
+	c.codeKey = key
+
+	return nil
+}
+
+// ClearCode removes the access code protection from the applet.
+// If the applet is protected, the current code must be validated first.
+func (c *Card) ClearCode() error {
+	return c.ClearCodeContext(context.Background())
+}
+
+// ClearCodeContext is like ClearCode but honors the cancellation of ctx.
+func (c *Card) ClearCodeContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	data, err := tlv.EncodeSimple(tlv.New(tagKey))
+	if err != nil {
+		return err
+	}
+
+	if _, err = c.send(ctx, &iso.CAPDU{
+		Ins:  insSetCode,
+		P1:   0x00,
+		P2:   0x00,
+		Data: data,
+	}); err != nil {
+		return err
+	}
+
+	c.codeKey = nil
+
+	return nil
+}
+
+// Validate unlocks a protected applet by performing a mutual
+// authentication with a key derived from the access code.
+//
+// The derived key is retained to unlock the applet again whenever it
+// is selected anew by a short transaction or a recovery.
+//
+// Like SetCode, it has not been verified against a key yet.
+func (c *Card) Validate(code string) error {
+	return c.ValidateContext(context.Background(), code)
+}
+
+// ValidateContext is like Validate but honors the cancellation of ctx.
+func (c *Card) ValidateContext(ctx context.Context, code string) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.challenge == nil {
+		return ErrNotLocked
+	}
+
+	key, err := c.deriveKey(code)
+	if err != nil {
+		return err
+	}
+
+	if err := c.validate(ctx, key); err != nil {
+		return err
+	}
+
+	c.codeKey = key
+
+	return nil
+}
+
+// validate performs the mutual authentication with a derived key.
+// The caller must hold c.mu.
+func (c *Card) validate(ctx context.Context, key []byte) error {
+	response, err := applet.HMAC(c.codeAlgorithm, key, c.challenge)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := c.randomChallenge()
+	if err != nil {
+		return err
+	}
+
+	data, err := tlv.EncodeSimple(
+		tlv.New(tagResponse, response),
+		tlv.New(tagChallenge, challenge),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.send(ctx, &iso.CAPDU{
+		Ins:  insValidate,
+		P1:   0x00,
+		P2:   0x00,
+		Data: data,
+	})
+	if err != nil {
+		if errors.Is(err, iso.ErrReferenceDataNotUsable) {
+			return fmt.Errorf("%w: %w", ErrWrongCode, err)
+		}
+
+		return err
+	}
+
+	tvs, err := tlv.DecodeSimple(resp)
+	if err != nil {
+		return err
+	}
+
+	expected, err := applet.HMAC(c.codeAlgorithm, key, challenge)
+	if err != nil {
+		return err
+	}
+
+	for _, tv := range tvs {
+		if tv.Tag != tagResponse {
+			continue
+		}
+
+		if !hmac.Equal(tv.Value, expected) {
+			return ErrCardAuthentication
+		}
+
+		c.challenge = nil
+
+		return nil
+	}
+
+	return ErrMissingResponse
+}
+
+func (c *Card) deriveKey(code string) ([]byte, error) {
+	if len(c.id) == 0 {
+		return nil, ErrMissingDeviceID
+	}
+
+	return pbkdf2.Key(sha1.New, code, c.id, codeIterations, codeKeyLength)
+}
+
+func (c *Card) randomChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	if _, err := io.ReadFull(c.Rand, challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	return challenge, nil
+}
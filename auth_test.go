@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestAccessCode runs against the emulator as no transcript of the
+// access code commands has been recorded from a key yet.
+func TestAccessCode(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		c.Rand = bytes.NewReader(fromHex("0001020304050607" + "08090a0b0c0d0e0f" + "1011121314151617"))
+
+		traces := []feitian.Trace{}
+		c.Tracer = func(t feitian.Trace) {
+			traces = append(traces, t)
+		}
+
+		require.False(c.Locked())
+
+		err := c.Validate("secret")
+		require.ErrorIs(err, feitian.ErrNotLocked)
+
+		err = c.SetCode("secret")
+		require.NoError(err)
+
+		err = c.Select()
+		require.NoError(err)
+		require.True(c.Locked())
+
+		err = c.Validate("wrong")
+		require.ErrorIs(err, feitian.ErrWrongCode)
+		require.True(c.Locked())
+
+		err = c.Validate("secret")
+		require.NoError(err)
+		require.False(c.Locked())
+
+		err = c.ClearCode()
+		require.NoError(err)
+
+		err = c.Select()
+		require.NoError(err)
+		require.False(c.Locked())
+
+		// The challenges and responses of the authentication are redacted
+		redacted := 0
+
+		for _, t := range traces {
+			for _, buf := range [][]byte{t.Data, t.Response} {
+				tvs, err := tlv.DecodeSimple(buf)
+				if err != nil {
+					continue // Select command
+				}
+
+				for _, tv := range tvs {
+					if tv.Tag == 0x54 || tv.Tag == 0x55 { // Challenge and response
+						require.Equal(make([]byte, len(tv.Value)), tv.Value, t.Name())
+						redacted++
+					}
+				}
+			}
+		}
+
+		require.Equal(8, redacted)
+	})
+}
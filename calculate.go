@@ -4,23 +4,123 @@
 package feitian
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"time"
 
 	iso "cunicu.li/go-iso7816"
 	"cunicu.li/go-iso7816/encoding/tlv"
 )
 
-var ErrMissingResponse = errors.New("missing response")
+var (
+	ErrMissingResponse = errors.New("missing response")
+	ErrValidityTooLong = errors.New("requested validity exceeds the time step")
+	ErrInvalidTimestep = errors.New("invalid time step")
+)
 
+// Calculate calculates the current TOTP value or the next HOTP value.
+//
+// For credentials with TouchRequired, the applet waits for the button
+// of the key to be touched. If it is not touched in time, ErrTouchTimeout
+// is returned. Users can be asked to touch the key by Card.TouchPrompt.
+//
+// If Card.Journal can not be updated, the code is returned
+// along with an error wrapping ErrJournal.
+//
+// The challenge is derived from the current time of c.Clock.
+// The applet does not report the kind of the credential. Hence, no
+// validity window is attached to the returned code. See CalculateTOTP.
 func (c *Card) Calculate(slot Slot, name string) (Code, error) {
-	challenge := ChallengeTOTP(c.Clock(), c.Timestep)
-	return c.CalculateWithChallenge(slot, name, challenge, false)
+	return c.CalculateContext(context.Background(), slot, name)
+}
+
+// CalculateContext is like Calculate but honors the cancellation of ctx.
+func (c *Card) CalculateContext(ctx context.Context, slot Slot, name string) (Code, error) {
+	if err := ValidateTimestep(c.Timestep); err != nil {
+		return Code{}, err
+	}
+
+	return c.CalculateWithChallengeContext(ctx, slot, name, ChallengeTOTP(c.Clock(), c.Timestep), false)
+}
+
+// CalculateTOTP is like Calculate but attaches the validity window
+// of the current time step to the returned code.
+//
+// The caller must make sure that the credential is a TOTP credential.
+func (c *Card) CalculateTOTP(slot Slot, name string) (Code, error) {
+	return c.CalculateTOTPContext(context.Background(), slot, name)
+}
+
+// CalculateTOTPContext is like CalculateTOTP but honors the cancellation of ctx.
+func (c *Card) CalculateTOTPContext(ctx context.Context, slot Slot, name string) (Code, error) {
+	if err := ValidateTimestep(c.Timestep); err != nil {
+		return Code{}, err
+	}
+
+	now := c.Clock()
+
+	code, err := c.CalculateWithChallengeContext(ctx, slot, name, ChallengeTOTP(now, c.Timestep), false)
+	if err != nil && !errors.Is(err, ErrJournal) {
+		return Code{}, err
+	}
+
+	code.ValidFrom, code.ValidUntil = windowTOTP(now, c.Timestep)
+
+	return code, err
+}
+
+// CalculateValidFor is like CalculateTOTP but guarantees that the returned
+// TOTP code remains valid for at least d.
+//
+// If the current time step ends earlier, it waits for the next one.
+// This avoids handing out codes which expire before a user has typed them.
+func (c *Card) CalculateValidFor(slot Slot, name string, d time.Duration) (Code, error) {
+	return c.CalculateValidForContext(context.Background(), slot, name, d)
+}
+
+// CalculateValidForContext is like CalculateValidFor but honors the cancellation of ctx.
+// This includes the wait for the next time step.
+func (c *Card) CalculateValidForContext(ctx context.Context, slot Slot, name string, d time.Duration) (Code, error) {
+	if err := ValidateTimestep(c.Timestep); err != nil {
+		return Code{}, err
+	} else if d >= c.Timestep {
+		return Code{}, fmt.Errorf("%w: %s >= %s", ErrValidityTooLong, d, c.Timestep)
+	}
+
+	now := c.Clock()
+
+	if _, until := windowTOTP(now, c.Timestep); until.Sub(now) < d {
+		t := time.NewTimer(until.Sub(now))
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return Code{}, ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return c.CalculateTOTPContext(ctx, slot, name)
 }
 
 // CalculateWithChallenge the OTP value.
 func (c *Card) CalculateWithChallenge(slot Slot, name string, challenge []byte, truncate bool) (Code, error) {
+	return c.CalculateWithChallengeContext(context.Background(), slot, name, challenge, truncate)
+}
+
+// CalculateWithChallengeContext is like CalculateWithChallenge but honors the cancellation of ctx.
+func (c *Card) CalculateWithChallengeContext(ctx context.Context, slot Slot, name string, challenge []byte, truncate bool) (Code, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.calculate(ctx, slot, name, challenge, truncate)
+}
+
+// calculate sends the calculate command.
+// The caller must hold c.mu.
+func (c *Card) calculate(ctx context.Context, slot Slot, name string, challenge []byte, truncate bool) (Code, error) {
 	if err := checkSlot(slot); err != nil {
 		return Code{}, err
 	} else if err := checkName(name); err != nil {
@@ -40,36 +140,72 @@ func (c *Card) CalculateWithChallenge(slot Slot, name string, challenge []byte,
 		p1 = 0x01
 	}
 
-	resp, err := c.Send(&iso.CAPDU{
+	stopPrompt := c.startTouchPrompt()
+
+	resp, err := c.send(ctx, &iso.CAPDU{
 		Ins:  insCalculate,
 		P1:   p1,
 		P2:   byte(slot),
 		Data: data,
 	})
+
+	stopPrompt()
+
 	if err != nil {
-		return Code{}, err
+		return Code{}, wrapCalculateStatus(err)
 	}
 
-	tvs, err := tlv.DecodeSimple(resp)
+	code, err := parseCalculate(resp)
 	if err != nil {
 		return Code{}, err
 	}
 
-	for _, tv := range tvs {
-		switch tv.Tag {
-		case tagResponse, tagTResponse:
-			return Code{
-				Digits:    int(tv.Value[0]),
-				Digest:    tv.Value[1:],
-				Truncated: tv.Tag == tagTResponse,
-			}, nil
-		}
+	// The code has been calculated even if the journal can not be updated
+	return code, c.updateJournal(ctx, func(j *Journal, deviceID string) error {
+		return j.advance(deviceID, slot, name)
+	})
+}
+
+// startTouchPrompt calls c.TouchPrompt unless the returned function
+// is called within c.TouchPromptDelay.
+func (c *Card) startTouchPrompt() (stop func()) {
+	if c.TouchPrompt == nil {
+		return func() {}
+	}
+
+	delay := c.TouchPromptDelay
+	if delay == 0 {
+		delay = DefaultTouchPromptDelay
 	}
 
-	return Code{}, ErrMissingResponse
+	t := time.AfterFunc(delay, c.TouchPrompt)
+
+	return func() {
+		t.Stop()
+	}
 }
 
+// ValidateTimestep checks that ts is a whole number of seconds.
+// TOTP counters are derived from Unix timestamps in seconds.
+func ValidateTimestep(ts time.Duration) error {
+	if ts < time.Second || ts%time.Second != 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidTimestep, ts)
+	}
+
+	return nil
+}
+
+// ChallengeTOTP returns the challenge for the time step which contains t.
+// It panics if ts is shorter than a second. See ValidateTimestep.
 func ChallengeTOTP(t time.Time, ts time.Duration) []byte {
 	counter := t.Unix() / int64(ts.Seconds())
 	return binary.BigEndian.AppendUint64(nil, uint64(counter)) //nolint:gosec
 }
+
+// windowTOTP returns the start and end of the time step which contains t.
+func windowTOTP(t time.Time, ts time.Duration) (from, until time.Time) {
+	step := int64(ts.Seconds())
+	from = time.Unix(t.Unix()/step*step, 0)
+
+	return from, from.Add(ts)
+}
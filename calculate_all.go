@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
+var ErrMalformedResponse = errors.New("malformed response")
+
+// CalculateAllItem is a single entry of the result of CalculateAll.
+type CalculateAllItem struct {
+	ListItem
+
+	Code Code
+
+	// Calculated is false for credentials which the applet skipped.
+	// This is the case for HOTP and static password credentials as well
+	// as credentials which require a touch.
+	Calculated bool
+}
+
+type calculateAllOptions struct {
+	list bool
+}
+
+// CalculateAllOption configures CalculateAll.
+type CalculateAllOption func(o *calculateAllOptions)
+
+// WithList completes the items returned by CalculateAll with the slot,
+// kind and algorithm of the credentials. These are taken from listing
+// the credentials of Slot1 and Slot2 which requires two more commands.
+func WithList() CalculateAllOption {
+	return func(o *calculateAllOptions) {
+		o.list = true
+	}
+}
+
+// CalculateAll calculates the OTP values of all slots in a single command.
+//
+// The applet only calculates TOTP credentials. All other credentials are
+// reported with Calculated set to false.
+//
+// The response of the applet only contains the names of the credentials.
+// Hence, the slot of the items is SlotUnknown. Their kind is only known to
+// be TOTP for calculated items. Pass WithList() to complete the items.
+func (c *Card) CalculateAll(challenge []byte, opts ...CalculateAllOption) ([]CalculateAllItem, error) {
+	return c.CalculateAllContext(context.Background(), challenge, opts...)
+}
+
+// CalculateAllContext is like CalculateAll but honors the cancellation of ctx.
+func (c *Card) CalculateAllContext(ctx context.Context, challenge []byte, opts ...CalculateAllOption) ([]CalculateAllItem, error) {
+	o := calculateAllOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	data, err := tlv.EncodeSimple(tlv.New(tagChallenge, challenge))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, &iso.CAPDU{
+		Ins:  insCalculateAll,
+		P1:   0x00,
+		P2:   0x00,
+		Data: data,
+	})
+	if err != nil {
+		return nil, wrapStatus(err)
+	}
+
+	items, err := parseCalculateAll(resp, c.ExperimentalTouch)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.list {
+		return items, nil
+	}
+
+	listed, err := c.list(ctx, Slot1, Slot2)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeCalculateAll(items, listed)
+}
+
+// mergeCalculateAll completes the items returned by the applet
+// with the properties of the listed credentials of the same name.
+// Each listed credential is only used once as both slots may hold
+// credentials with the same name.
+func mergeCalculateAll(items []CalculateAllItem, listed []ListItem) ([]CalculateAllItem, error) {
+	used := make([]bool, len(listed))
+
+	for i := range items {
+		item := &items[i]
+
+		j := -1
+		for k, l := range listed {
+			if !used[k] && l.Name == item.Name {
+				j = k
+				break
+			}
+		}
+
+		if j < 0 {
+			return nil, fmt.Errorf("%w: unknown credential %q", ErrMalformedResponse, item.Name)
+		}
+
+		used[j] = true
+
+		touch := item.Touch
+		item.ListItem = listed[j]
+
+		if touch != TouchUnknown {
+			item.Touch = touch
+		}
+	}
+
+	return items, nil
+}
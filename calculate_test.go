@@ -4,7 +4,9 @@
 package feitian_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -88,3 +90,49 @@ func TestCalculateStaticPassword(t *testing.T) {
 		require.Equal(pass, code.Digest)
 	})
 }
+
+// TestCalculateValidity runs against the emulator as no transcript
+// of it has been recorded from a key yet.
+func TestCalculateValidity(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		v := vectorsTOTP[2] // 1111111109 is one second before the end of its time step
+
+		err := c.Put(feitian.Slot1, v.Name, v.Secret, v.Algorithm, v.Kind, v.Digits, v.Counter)
+		require.NoError(err)
+
+		now := v.Time
+		c.Clock = func() time.Time {
+			return now
+		}
+
+		code, err := c.CalculateTOTP(feitian.Slot1, v.Name)
+		require.NoError(err)
+		require.Equal(v.Code, code.OTP())
+		require.Equal(time.Unix(1111111080, 0), code.ValidFrom)
+		require.Equal(time.Unix(1111111110, 0), code.ValidUntil)
+
+		_, err = c.CalculateValidFor(feitian.Slot1, v.Name, feitian.DefaultTimeStep)
+		require.ErrorIs(err, feitian.ErrValidityTooLong)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err = c.CalculateValidForContext(ctx, feitian.Slot1, v.Name, 5*time.Second)
+		require.ErrorIs(err, context.Canceled)
+
+		// Wait for the next time step as only 50ms remain in the current one
+		now = time.Unix(1111111109, int64(950*time.Millisecond))
+		c.Clock = func() time.Time {
+			defer func() { now = time.Unix(1111111110, 0) }()
+			return now
+		}
+
+		code, err = c.CalculateValidFor(feitian.Slot1, v.Name, 5*time.Second)
+		require.NoError(err)
+		require.Equal(vectorsTOTP[4].Code, code.OTP())
+		require.Equal(time.Unix(1111111110, 0), code.ValidFrom)
+		require.Equal(time.Unix(1111111140, 0), code.ValidUntil)
+	})
+}
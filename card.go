@@ -4,13 +4,21 @@
 package feitian
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"sync"
 	"time"
 
 	iso "cunicu.li/go-iso7816"
 	"cunicu.li/go-iso7816/devices/feitian"
-	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath/internal/applet"
 )
 
 type Slot byte
@@ -19,9 +27,13 @@ const (
 	Slot1       Slot = 0x00
 	Slot2       Slot = 0x01
 	SlotDefault Slot = 0xF0
+	SlotUnknown Slot = 0xFF // The applet did not report the slot
 )
 
-const DefaultTimeStep = 30 * time.Second
+const (
+	DefaultTimeStep         = 30 * time.Second
+	DefaultTouchPromptDelay = 500 * time.Millisecond
+)
 
 type Algorithm byte
 
@@ -30,37 +42,48 @@ const (
 	SHA256 Algorithm = 0x02
 )
 
+// Hash returns the constructor of the hash function used by the algorithm.
+func (a Algorithm) Hash() (func() hash.Hash, error) {
+	switch a {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
 const (
-	tagName       tlv.Tag = 0x51
-	tagNameList   tlv.Tag = 0x52
-	tagKey        tlv.Tag = 0x53
-	tagChallenge  tlv.Tag = 0x54
-	tagResponse   tlv.Tag = 0x55
-	tagProperty   tlv.Tag = 0x58
-	tagVersion    tlv.Tag = 0x59
-	tagIMF        tlv.Tag = 0x5A // Initial Moving Factor (counter value for HOTP)
-	tagAlgorithm  tlv.Tag = 0x5B
-	tagTouch      tlv.Tag = 0x5C
-	tagTResponse  tlv.Tag = 0x76 // Truncated
-	tagNoResponse tlv.Tag = 0x77
+	tagName       = applet.TagName
+	tagNameList   = applet.TagNameList
+	tagKey        = applet.TagKey
+	tagChallenge  = applet.TagChallenge
+	tagResponse   = applet.TagResponse
+	tagVersion    = applet.TagVersion
+	tagIMF        = applet.TagIMF
+	tagAlgorithm  = applet.TagAlgorithm
+	tagTouch      = applet.TagTouch
+	tagTResponse  = applet.TagTResponse
+	tagNoResponse = applet.TagNoResponse
 )
 
 const (
-	insSetCode         iso.Instruction = 0x02
-	insReset           iso.Instruction = 0x07
-	insDelete          iso.Instruction = 0x08
-	insPut             iso.Instruction = 0x09
-	insList            iso.Instruction = 0x17
-	insValidate        iso.Instruction = 0x19
-	insCalculateAll    iso.Instruction = 0x1A
-	insSendRemaining   iso.Instruction = 0x1B
-	insCalculate       iso.Instruction = 0xA2
-	insLanguage        iso.Instruction = 0xA7
-	insSendRemainingFT iso.Instruction = 0xC0
-	insApplication     iso.Instruction = 0xE1
-	insSetDefault      iso.Instruction = 0xE5
-	insGetDefault      iso.Instruction = 0xE6
-	insSwapSlot        iso.Instruction = 0xE7
+	insSetCode         = applet.InsSetCode
+	insReset           = applet.InsReset
+	insDelete          = applet.InsDelete
+	insPut             = applet.InsPut
+	insList            = applet.InsList
+	insValidate        = applet.InsValidate
+	insCalculateAll    = applet.InsCalculateAll
+	insSendRemaining   = applet.InsSendRemaining
+	insCalculate       = applet.InsCalculate
+	insLanguage        = applet.InsLanguage
+	insSendRemainingFT = applet.InsSendRemainingFT
+	insApplication     = applet.InsApplication
+	insSetDefault      = applet.InsSetDefault
+	insGetDefault      = applet.InsGetDefault
+	insSwapSlot        = applet.InsSwapSlot
 )
 
 type AppState byte
@@ -73,10 +96,10 @@ const (
 type Kind byte
 
 const (
-	HOTP              Kind = 0x10
-	TOTP              Kind = 0x20
-	StaticPassword    Kind = 0x30
-	ChallengeResponse Kind = 0x40
+	HOTP              Kind = applet.KindHOTP
+	TOTP              Kind = applet.KindTOTP
+	StaticPassword    Kind = applet.KindStaticPassword
+	ChallengeResponse Kind = applet.KindChallengeResponse
 )
 
 var (
@@ -84,38 +107,131 @@ var (
 	ErrNameTooLong   = errors.New("name is too long")
 	ErrInvalidSlot   = errors.New("invalid slot")
 	ErrInvalidDigits = errors.New("number of digits must be either 6 or 8")
+
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
 )
 
+// Card is a FEITIAN key with an OTP applet.
+//
+// A Card is safe for concurrent use by multiple goroutines.
+// Each operation is serialized as a whole, including operations like List
+// which exchange multiple APDUs with the applet.
+// Commands sent directly via the embedded iso7816.Card bypass this serialization.
 type Card struct {
 	*feitian.Card
 
 	Clock    func() time.Time
 	Timestep time.Duration
+	Rand     io.Reader
+
+	// Journal optionally tracks the counters of HOTP credentials.
+	Journal *Journal
+
+	// Tracer is optionally called for each command exchanged with the applet.
+	Tracer Tracer
+
+	// TraceSecrets disables the redaction of secrets in the traces passed to Tracer.
+	TraceSecrets bool
+
+	// TouchPrompt is optionally called if the applet has not answered a
+	// calculate command within TouchPromptDelay. This usually means that
+	// the credential requires a touch and the applet waits for the button
+	// of the key to be touched. TouchPrompt is called from a separate goroutine.
+	TouchPrompt func()
 
-	tx *iso.Transaction
+	// TouchPromptDelay defaults to DefaultTouchPromptDelay.
+	TouchPromptDelay time.Duration
+
+	// ExperimentalTouch allows to program credentials with TouchRequired
+	// and decodes the touch policy from the responses of the applet.
+	//
+	// The encoding of TouchRequired has not been confirmed by a transcript
+	// recorded from a key yet. Without this option, programming a credential
+	// with TouchRequired fails with ErrExperimentalTouch and the touch
+	// policy is always reported as TouchUnknown.
+	ExperimentalTouch bool
+
+	// Recovery optionally recovers from failed commands, e.g. after the
+	// key has been reset or another application selected a different applet.
+	// The failed command is retried once after the recovery.
+	// Commands are not retried if Recovery is nil.
+	//
+	// After RecoverReconnect, only commands which do not modify the applet
+	// like List are retried. All others might have been executed already.
+	// A repeated Swap would undo itself and a repeated Calculate of a HOTP
+	// credential would advance its counter twice. Hence, their error is
+	// returned although the connection has been recovered.
+	Recovery RecoveryPolicy
+
+	mu    sync.Mutex
+	tx    *iso.Transaction
+	short bool
+
+	selected   bool
+	stale      bool // The device information might have been changed by Reset
+	recovering bool
+	codeKey    []byte
+
+	info          DeviceInfo
+	id            []byte
+	challenge     []byte
+	codeAlgorithm Algorithm
+}
+
+// CardOption configures a Card created by NewCard.
+type CardOption func(c *Card)
+
+// WithShortTransactions lets each operation open and close its own
+// PC/SC transaction instead of holding a single transaction
+// from NewCard() until Close().
+//
+// This allows other applications to use the key in between operations.
+// As they might select a different applet, the OTP applet is selected
+// again at the beginning of each operation. If the applet is protected
+// by an access code, it is unlocked again with the key derived by Validate().
+func WithShortTransactions() CardOption {
+	return func(c *Card) {
+		c.short = true
+	}
 }
 
 // NewCard initializes a new card.
-func NewCard(pcscCard iso.PCSCCard) (*Card, error) {
+//
+// By default, the card is used exclusively within a single transaction
+// until Close() is called. This is the most efficient mode for batch
+// operations like provisioning. See WithShortTransactions() for an alternative.
+func NewCard(pcscCard iso.PCSCCard, opts ...CardOption) (*Card, error) {
 	isoCard := iso.NewCard(pcscCard)
 	isoCard.InsGetRemaining = insSendRemaining
 
-	tx, err := isoCard.NewTransaction()
-	if err != nil {
-		return nil, fmt.Errorf("failed to initiate transaction: %w", err)
-	}
-
-	return &Card{
+	c := &Card{
 		Card:     &feitian.Card{Card: isoCard},
 		Clock:    time.Now,
 		Timestep: DefaultTimeStep,
+		Rand:     rand.Reader,
+	}
 
-		tx: tx,
-	}, nil
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if !c.short {
+		tx, err := isoCard.NewTransaction()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initiate transaction: %w", err)
+		}
+
+		c.tx = tx
+	}
+
+	return c, nil
 }
 
 // Close terminates the session.
 func (c *Card) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.tx != nil {
 		if err := c.tx.EndTransaction(); err != nil {
 			return err
@@ -125,9 +241,162 @@ func (c *Card) Close() error {
 	return nil
 }
 
+// Select selects the OTP applet.
+//
+// The information reported by the applet is available via DeviceInfo().
+// If the applet is protected by an access code, it must be
+// unlocked by Validate() before any other operation.
 func (c *Card) Select() error {
-	_, err := c.Card.Select(iso.AidFeitianOTP)
-	return err
+	return c.SelectContext(context.Background())
+}
+
+// SelectContext is like Select but honors the cancellation of ctx.
+func (c *Card) SelectContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	} else if err := c.beginTransaction(ctx, false); err != nil {
+		return err
+	}
+
+	c.codeKey = nil
+
+	return c.selectApplet()
+}
+
+// selectApplet selects the OTP applet and parses its response.
+// The caller must hold c.mu.
+func (c *Card) selectApplet() error {
+	resp, err := c.transmit(&iso.CAPDU{
+		Ins:  iso.InsSelect,
+		P1:   0x04,
+		P2:   0x00,
+		Data: iso.AidFeitianOTP,
+		Ne:   iso.MaxLenRespDataStandard,
+	})
+	if err != nil {
+		return err
+	}
+
+	r, err := parseSelect(resp)
+	if err != nil {
+		return err
+	}
+
+	c.info = r.info
+	c.id = r.id
+	c.challenge = r.challenge
+	c.codeAlgorithm = r.codeAlgorithm
+
+	c.selected = true
+	c.stale = false
+
+	return nil
+}
+
+// refreshInfo selects the OTP applet again if the device information
+// might have been changed by Reset. The caller must hold c.mu.
+//
+// The applet is not selected by Reset itself as the select would
+// needlessly precede every command which does not require the ID.
+func (c *Card) refreshInfo(ctx context.Context) error {
+	if !c.stale {
+		return nil
+	} else if err := ctx.Err(); err != nil {
+		return err
+	} else if err := c.beginTransaction(ctx, false); err != nil {
+		return err
+	}
+
+	return c.selectApplet()
+}
+
+// updateJournal applies fn to c.Journal with the current device ID.
+// The caller must hold c.mu.
+func (c *Card) updateJournal(ctx context.Context, fn func(j *Journal, deviceID string) error) error {
+	if c.Journal == nil {
+		return nil
+	}
+
+	if err := c.refreshInfo(ctx); err != nil {
+		return fmt.Errorf("%w: failed to read device ID: %w", ErrJournal, err)
+	}
+
+	return fn(c.Journal, c.info.ID)
+}
+
+// beginTransaction starts the transaction of an operation if the card
+// uses short transactions and the operation has not yet started one.
+// The transaction is ended by unlock().
+//
+// If reselect is true, the OTP applet is selected again and unlocked
+// with the retained access code key.
+// The caller must hold c.mu.
+func (c *Card) beginTransaction(ctx context.Context, reselect bool) error {
+	if !c.short || c.tx != nil {
+		return nil
+	}
+
+	tx, err := c.Card.NewTransaction()
+	if err != nil {
+		return fmt.Errorf("failed to initiate transaction: %w", err)
+	}
+
+	c.tx = tx
+
+	if !reselect || !c.selected {
+		return nil
+	}
+
+	return c.reselect(ctx)
+}
+
+// unlock ends the transaction of the current operation
+// if the card uses short transactions and releases c.mu.
+func (c *Card) unlock() {
+	if c.short && c.tx != nil {
+		c.tx.Close() //nolint:errcheck
+		c.tx = nil
+	}
+
+	c.mu.Unlock()
+}
+
+// send transmits a single command to the applet.
+//
+// Cancellation of ctx is only checked before the command is sent.
+// An exchange which already started, including the retrieval of
+// chained response data, is always completed so that the applet
+// is left in a consistent state.
+func (c *Card) send(ctx context.Context, cmd *iso.CAPDU) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if err := c.beginTransaction(ctx, true); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.transmit(cmd)
+	if err == nil || c.Recovery == nil || !c.selected || c.recovering {
+		return resp, err
+	}
+
+	rec := c.Recovery(err)
+	if rec == RecoverNone {
+		return nil, err
+	}
+
+	if rerr := c.recover(ctx, rec); rerr != nil {
+		return nil, fmt.Errorf("failed to recover from %w: %w", err, rerr)
+	}
+
+	// The applet might have executed the command before the connection got lost
+	if rec == RecoverReconnect && !isIdempotent(cmd.Ins) {
+		return nil, err
+	}
+
+	return c.transmit(cmd)
 }
 
 func checkName(name string) error {
@@ -4,16 +4,29 @@
 package feitian_test
 
 import (
+	"errors"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	iso "cunicu.li/go-iso7816"
 	"cunicu.li/go-iso7816/filter"
 	"cunicu.li/go-iso7816/test"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/emulator"
 )
 
+var errNoTransaction = errors.New("no transaction")
+
+// selectFIDO is sent by other applications using the FIDO applet of the key.
+//
+//nolint:gochecknoglobals
+var selectFIDO = []byte{0x00, 0xA4, 0x04, 0x00, 0x08, 0xA0, 0x00, 0x00, 0x06, 0x47, 0x2F, 0x00, 0x01}
+
 func withCard(t *testing.T, reset bool, cb func(t *testing.T, c *feitian.Card)) {
 	test.WithCard(t, filter.IsFeitian, func(t *testing.T, c *iso.Card) {
 		require := require.New(t)
@@ -40,3 +53,173 @@ func withCardAndVectors(t *testing.T, vectors []vector, reset bool, cb func(t *t
 		}
 	})
 }
+
+// withEmulator runs cb against a freshly selected software emulator.
+// It is used by tests for which no transcript has been recorded from a key yet.
+func withEmulator(t *testing.T, cb func(t *testing.T, c *feitian.Card)) {
+	require := require.New(t)
+
+	c, err := feitian.NewCard(emulator.New())
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	cb(t, c)
+
+	err = c.Close()
+	require.NoError(err)
+}
+
+// yieldingCard gives other goroutines the chance to run
+// between APDUs like the I/O to a real key does.
+type yieldingCard struct {
+	iso.PCSCCard
+}
+
+func (c yieldingCard) Transmit(cmd []byte) ([]byte, error) {
+	runtime.Gosched()
+	return c.PCSCCard.Transmit(cmd)
+}
+
+func TestConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	c, err := feitian.NewCard(yieldingCard{emulator.New()})
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	err = c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+	require.NoError(err)
+
+	err = c.Put(feitian.Slot2, "slot2", testSecretSHA256, feitian.SHA256, feitian.TOTP, 8, 0)
+	require.NoError(err)
+
+	var wg sync.WaitGroup
+
+	for range 4 {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			for range 50 {
+				err := c.Swap()
+				assert.NoError(t, err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			// List spans multiple APDUs which must not be interleaved with a Swap
+			for range 50 {
+				items, err := c.List()
+				if assert.NoError(t, err) && assert.Len(t, items, 2) {
+					assert.NotEqual(t, items[0].Name, items[1].Name)
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for range 50 {
+				_, err := c.CalculateAll(feitian.ChallengeTOTP(time.Now(), feitian.DefaultTimeStep))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	err = c.Close()
+	require.NoError(err)
+}
+
+// transactionCard tracks the PC/SC transactions and
+// rejects commands which are sent outside of them.
+type transactionCard struct {
+	*emulator.Card
+
+	active bool
+	begun  int
+}
+
+func (c *transactionCard) BeginTransaction() error {
+	c.active = true
+	c.begun++
+
+	return nil
+}
+
+func (c *transactionCard) EndTransaction() error {
+	c.active = false
+	return nil
+}
+
+func (c *transactionCard) Transmit(cmd []byte) ([]byte, error) {
+	if !c.active {
+		return nil, errNoTransaction
+	}
+
+	return c.Card.Transmit(cmd)
+}
+
+func TestShortTransactions(t *testing.T) {
+	require := require.New(t)
+
+	e := &transactionCard{Card: emulator.New()}
+
+	c, err := feitian.NewCard(e, feitian.WithShortTransactions())
+	require.NoError(err)
+	require.Equal(0, e.begun)
+
+	err = c.Select()
+	require.NoError(err)
+	require.False(e.active)
+	require.Equal(1, e.begun)
+
+	err = c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+	require.NoError(err)
+	require.False(e.active)
+	require.Equal(2, e.begun)
+
+	// Another application selects a different applet in between
+	_, err = e.Card.Transmit(selectFIDO)
+	require.NoError(err)
+
+	// List spans multiple commands within a single transaction
+	items, err := c.List()
+	require.NoError(err)
+	require.Len(items, 1)
+	require.False(e.active)
+	require.Equal(3, e.begun)
+
+	err = c.Close()
+	require.NoError(err)
+}
+
+func TestLongTransaction(t *testing.T) {
+	require := require.New(t)
+
+	e := &transactionCard{Card: emulator.New()}
+
+	c, err := feitian.NewCard(e)
+	require.NoError(err)
+	require.True(e.active)
+
+	err = c.Select()
+	require.NoError(err)
+
+	_, err = c.List()
+	require.NoError(err)
+	require.True(e.active)
+	require.Equal(1, e.begun)
+
+	err = c.Close()
+	require.NoError(err)
+	require.False(e.active)
+}
@@ -0,0 +1,514 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/provision"
+)
+
+var errAborted = errors.New("aborted by user")
+
+type command func(a *app, args []string) error
+
+//nolint:gochecknoglobals
+var commands = map[string]command{
+	"list":      (*app).list,
+	"put":       (*app).put,
+	"code":      (*app).code,
+	"calculate": (*app).calculate,
+	"delete":    (*app).delete,
+	"swap":      (*app).swap,
+	"reset":     (*app).reset,
+	"language":  (*app).language,
+	"default":   (*app).defaultCredential,
+	"provision": (*app).provision,
+}
+
+type app struct {
+	card *feitian.Card
+
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+
+	json  bool
+	yes   bool
+	flags *flag.FlagSet
+}
+
+type listItem struct {
+	Slot      string `json:"slot"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Algorithm string `json:"algorithm"`
+	Touch     string `json:"touch"`
+}
+
+func (a *app) list(args []string) error {
+	if err := a.parse("list", args, nil); err != nil {
+		return err
+	}
+
+	items, err := a.card.List()
+	if err != nil {
+		return err
+	}
+
+	out := []listItem{}
+	for _, item := range items {
+		out = append(out, listItem{
+			Slot:      item.Slot.String(),
+			Name:      item.Name,
+			Kind:      item.Kind.String(),
+			Algorithm: item.Algorithm.String(),
+			Touch:     item.Touch.String(),
+		})
+	}
+
+	return a.print(out, func(w io.Writer) {
+		for _, item := range out {
+			fmt.Fprintf(w, "%-7s  %-8s  %-6s  %-8s  %s\n", item.Slot, item.Kind, item.Algorithm, item.Touch, item.Name)
+		}
+	})
+}
+
+func (a *app) put(args []string) error {
+	var (
+		slot    string
+		uri     string
+		name    string
+		secret  string
+		pass    string
+		alg     string
+		kind    string
+		digits  int
+		counter uint
+		touch   bool
+	)
+
+	if err := a.parse("put", args, func(f *flag.FlagSet) {
+		f.StringVar(&slot, "slot", "1", "Slot (1 or 2)")
+		f.StringVar(&uri, "uri", "", "otpauth:// URI describing the credential")
+		f.StringVar(&name, "name", "", "Name of the credential")
+		f.StringVar(&secret, "secret", "", "Base32-encoded secret")
+		f.StringVar(&pass, "password", "", "Password of a static password credential")
+		f.StringVar(&alg, "algorithm", "SHA1", "Hash algorithm (SHA1 or SHA256)")
+		f.StringVar(&kind, "kind", "totp", "Kind of credential (totp, hotp, static or chalresp)")
+		f.IntVar(&digits, "digits", 6, "Number of digits (6 or 8)")
+		f.UintVar(&counter, "counter", 0, "Initial counter value of HOTP credentials which is reached by calculating codes")
+		f.BoolVar(&touch, "touch", false, "Require a touch of the button for each calculation (requires --experimental-touch)")
+	}); err != nil {
+		return err
+	}
+
+	policy := feitian.TouchNone
+	if touch {
+		policy = feitian.TouchRequired
+	}
+
+	s, err := feitian.ParseSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	if uri != "" {
+		k, err := feitian.ParseURI(uri)
+		if err != nil {
+			return err
+		}
+
+		if name != "" {
+			k.Issuer = ""
+			k.Account = name
+		}
+
+		return a.card.PutURI(s, k, feitian.WithTouch(policy))
+	}
+
+	k, err := feitian.ParseKind(kind)
+	if err != nil {
+		return err
+	}
+
+	hashAlg, err := feitian.ParseAlgorithm(alg)
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	if k == feitian.StaticPassword {
+		key = []byte(pass)
+	} else if key, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(strings.TrimRight(secret, "="))); err != nil {
+		return fmt.Errorf("%w: %w", feitian.ErrInvalidSecret, err)
+	}
+
+	if len(key) == 0 {
+		return fmt.Errorf("%w: missing secret", feitian.ErrInvalidSecret)
+	}
+
+	return a.card.PutCredential(s, feitian.Credential{
+		Name:      name,
+		Kind:      k,
+		Algorithm: hashAlg,
+		Secret:    key,
+		Digits:    digits,
+		Counter:   uint32(counter), //nolint:gosec
+		Touch:     policy,
+	})
+}
+
+type codeResult struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+func (a *app) code(args []string) error {
+	var slot string
+
+	if err := a.parse("code", args, func(f *flag.FlagSet) {
+		f.StringVar(&slot, "slot", "1", "Slot (1 or 2)")
+	}); err != nil {
+		return err
+	}
+
+	s, name, err := a.slotAndName(slot)
+	if err != nil {
+		return err
+	}
+
+	code, err := a.card.Calculate(s, name)
+	if err != nil {
+		return err
+	} else if err := code.Validate(); err != nil {
+		return err
+	}
+
+	res := codeResult{
+		Name: name,
+		Code: code.OTP(),
+	}
+
+	return a.print(res, func(w io.Writer) {
+		fmt.Fprintln(w, res.Code)
+	})
+}
+
+type calculateResult struct {
+	Name     string `json:"name"`
+	Response string `json:"response"`
+}
+
+func (a *app) calculate(args []string) error {
+	var (
+		slot      string
+		challenge string
+	)
+
+	if err := a.parse("calculate", args, func(f *flag.FlagSet) {
+		f.StringVar(&slot, "slot", "1", "Slot (1 or 2)")
+		f.StringVar(&challenge, "challenge", "", "Hex-encoded challenge")
+	}); err != nil {
+		return err
+	}
+
+	s, name, err := a.slotAndName(slot)
+	if err != nil {
+		return err
+	}
+
+	chal, err := hex.DecodeString(challenge)
+	if err != nil {
+		return fmt.Errorf("invalid challenge: %w", err)
+	} else if len(chal) == 0 {
+		return fmt.Errorf("%w: missing challenge", errUsage)
+	}
+
+	code, err := a.card.CalculateWithChallenge(s, name, chal, false)
+	if err != nil {
+		return err
+	}
+
+	res := calculateResult{
+		Name:     name,
+		Response: hex.EncodeToString(code.Digest),
+	}
+
+	return a.print(res, func(w io.Writer) {
+		fmt.Fprintln(w, res.Response)
+	})
+}
+
+func (a *app) delete(args []string) error {
+	var slot string
+
+	if err := a.parse("delete", args, func(f *flag.FlagSet) {
+		f.StringVar(&slot, "slot", "1", "Slot (1 or 2)")
+	}); err != nil {
+		return err
+	}
+
+	s, name, err := a.slotAndName(slot)
+	if err != nil {
+		return err
+	}
+
+	if err := a.confirm(fmt.Sprintf("Delete credential %q from %s?", name, s.String())); err != nil {
+		return err
+	}
+
+	return a.card.Delete(s, name)
+}
+
+func (a *app) swap(args []string) error {
+	if err := a.parse("swap", args, nil); err != nil {
+		return err
+	}
+
+	if err := a.confirm("Swap the credentials of both slots?"); err != nil {
+		return err
+	}
+
+	return a.card.Swap()
+}
+
+func (a *app) reset(args []string) error {
+	if err := a.parse("reset", args, nil); err != nil {
+		return err
+	}
+
+	if err := a.confirm("Delete all credentials?"); err != nil {
+		return err
+	}
+
+	return a.card.Reset()
+}
+
+type languageResult struct {
+	Language string `json:"language"`
+}
+
+func (a *app) language(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%w: missing sub-command (get or set)", errUsage)
+	}
+
+	switch args[0] {
+	case "get":
+		lang, err := a.card.Language()
+		if err != nil {
+			return err
+		}
+
+		res := languageResult{
+			Language: lang.String(),
+		}
+
+		return a.print(res, func(w io.Writer) {
+			fmt.Fprintln(w, res.Language)
+		})
+
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: missing language (en or fr)", errUsage)
+		}
+
+		lang, err := feitian.ParseLanguage(args[1])
+		if err != nil {
+			return err
+		}
+
+		return a.card.SetLanguage(lang)
+
+	default:
+		return fmt.Errorf("%w: unknown sub-command: %s", errUsage, args[0])
+	}
+}
+
+type defaultResult struct {
+	Slot string `json:"slot"`
+	Name string `json:"name"`
+}
+
+func (a *app) defaultCredential(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%w: missing sub-command (get or set)", errUsage)
+	}
+
+	var slot string
+
+	if err := a.parse("default "+args[0], args[1:], func(f *flag.FlagSet) {
+		f.StringVar(&slot, "slot", "1", "Slot (1 or 2)")
+	}); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "get":
+		s, err := feitian.ParseSlot(slot)
+		if err != nil {
+			return err
+		}
+
+		name, err := a.card.Default(s)
+		if err != nil {
+			return err
+		}
+
+		res := defaultResult{
+			Slot: s.String(),
+			Name: name,
+		}
+
+		return a.print(res, func(w io.Writer) {
+			fmt.Fprintln(w, res.Name)
+		})
+
+	case "set":
+		s, name, err := a.slotAndName(slot)
+		if err != nil {
+			return err
+		}
+
+		return a.card.SetDefault(s, name)
+
+	default:
+		return fmt.Errorf("%w: unknown sub-command: %s", errUsage, args[0])
+	}
+}
+
+// provisionResult is the output of the provision command in JSON format.
+type provisionResult struct {
+	Actions  []string `json:"actions"`
+	Warnings []string `json:"warnings"`
+	Applied  bool     `json:"applied"`
+}
+
+func (a *app) provision(args []string) error {
+	var dryRun bool
+
+	if err := a.parse("provision", args, func(f *flag.FlagSet) {
+		f.BoolVar(&dryRun, "dry-run", false, "Only print the required changes")
+	}); err != nil {
+		return err
+	}
+
+	if a.flags.NArg() != 1 {
+		return fmt.Errorf("%w: expected configuration file", errUsage)
+	}
+
+	cfg, err := provision.LoadFile(a.flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	plan, err := provision.NewPlan(ctx, a.card, cfg)
+	if err != nil {
+		return err
+	}
+
+	res := provisionResult{
+		Actions:  []string{},
+		Warnings: []string{},
+	}
+
+	for _, action := range plan.Actions {
+		res.Actions = append(res.Actions, action.Description)
+	}
+
+	res.Warnings = append(res.Warnings, plan.Warnings...)
+
+	if !dryRun && !plan.Empty() {
+		fmt.Fprint(a.err, plan)
+
+		if err := a.confirm("Apply these changes?"); err != nil {
+			return err
+		}
+
+		if err := plan.Apply(ctx, a.card); err != nil {
+			return err
+		}
+
+		res.Applied = true
+	}
+
+	return a.print(res, func(w io.Writer) {
+		if dryRun || plan.Empty() {
+			fmt.Fprint(w, plan)
+		} else {
+			fmt.Fprintf(w, "Applied %d changes\n", len(plan.Actions))
+		}
+	})
+}
+
+// parse parses the flags of a sub-command.
+// The remaining positional arguments are stored in a.flags.
+func (a *app) parse(name string, args []string, setup func(f *flag.FlagSet)) error {
+	a.flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	a.flags.SetOutput(a.err)
+
+	if setup != nil {
+		setup(a.flags)
+	}
+
+	return a.flags.Parse(args)
+}
+
+func (a *app) slotAndName(slot string) (feitian.Slot, string, error) {
+	s, err := feitian.ParseSlot(slot)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if a.flags.NArg() != 1 {
+		return 0, "", fmt.Errorf("%w: expected credential name", errUsage)
+	}
+
+	return s, a.flags.Arg(0), nil
+}
+
+func (a *app) confirm(question string) error {
+	if a.yes {
+		return nil
+	}
+
+	fmt.Fprintf(a.err, "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(a.in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errAborted
+	}
+}
+
+func (a *app) print(v any, text func(w io.Writer)) error {
+	if a.json {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	text(a.out)
+
+	return nil
+}
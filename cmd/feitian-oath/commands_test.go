@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/emulator"
+)
+
+type session struct {
+	t    *testing.T
+	card *feitian.Card
+}
+
+func newSession(t *testing.T) *session {
+	card, err := feitian.NewCard(emulator.New())
+	require.NoError(t, err)
+
+	err = card.Select()
+	require.NoError(t, err)
+
+	return &session{t, card}
+}
+
+func (s *session) run(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	err := run(args, strings.NewReader(stdin), &stdout, &stderr, func(string, string) (*feitian.Card, func(), error) {
+		return s.card, func() {}, nil
+	})
+
+	return stdout.String(), err
+}
+
+func TestPutAndList(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	_, err := s.run("", "put", "--slot", "1", "--name", "test", "--secret", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	require.NoError(err)
+
+	_, err = s.run("", "put", "--slot", "2", "--touch", "--uri", "otpauth://hotp/ACME:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0")
+	require.ErrorIs(err, feitian.ErrExperimentalTouch)
+
+	_, err = s.run("", "--experimental-touch", "put", "--slot", "2", "--touch", "--uri", "otpauth://hotp/ACME:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0")
+	require.NoError(err)
+
+	out, err := s.run("", "--json", "list")
+	require.NoError(err)
+
+	var items []listItem
+	err = json.Unmarshal([]byte(out), &items)
+	require.NoError(err)
+	require.Equal([]listItem{
+		{Slot: "slot1", Name: "test", Kind: "totp", Algorithm: "SHA1", Touch: "unknown"},
+		{Slot: "slot2", Name: "ACME:alice", Kind: "hotp", Algorithm: "SHA1", Touch: "unknown"},
+	}, items)
+
+	out, err = s.run("", "code", "--slot", "2", "ACME:alice")
+	require.NoError(err)
+	require.Equal("755224\n", out) // RFC 4226 Appendix D, counter 0
+}
+
+func TestCodeMalformed(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	// A static password is not a one-time password
+	_, err := s.run("", "put", "--kind", "static", "--name", "test", "--password", "pass")
+	require.NoError(err)
+
+	_, err = s.run("", "code", "test")
+	require.ErrorIs(err, feitian.ErrMalformedCode)
+}
+
+func TestCalculate(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	_, err := s.run("", "put", "--kind", "chalresp", "--name", "test", "--secret", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	require.NoError(err)
+
+	out, err := s.run("", "--json", "calculate", "--challenge", "00", "test")
+	require.NoError(err)
+
+	var res calculateResult
+	err = json.Unmarshal([]byte(out), &res)
+	require.NoError(err)
+	require.Equal("test", res.Name)
+	require.Len(res.Response, 40)
+
+	_, err = s.run("", "calculate", "test")
+	require.ErrorIs(err, errUsage)
+}
+
+func TestDeleteConfirmation(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	_, err := s.run("", "put", "--kind", "static", "--name", "test", "--password", "hello")
+	require.NoError(err)
+
+	_, err = s.run("n\n", "delete", "test")
+	require.ErrorIs(err, errAborted)
+
+	_, err = s.run("", "delete", "test")
+	require.ErrorIs(err, errAborted)
+
+	_, err = s.run("y\n", "delete", "test")
+	require.NoError(err)
+
+	_, err = s.run("", "--yes", "delete", "test")
+	require.ErrorIs(err, feitian.ErrNoSuchCredential)
+}
+
+func TestLanguage(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	_, err := s.run("", "language", "set", "fr")
+	require.NoError(err)
+
+	out, err := s.run("", "language", "get")
+	require.NoError(err)
+	require.Equal("fr\n", out)
+
+	_, err = s.run("", "language", "set", "de")
+	require.ErrorIs(err, feitian.ErrUnsupportedLanguage)
+}
+
+func TestDefault(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	_, err := s.run("", "put", "--name", "test", "--secret", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	require.NoError(err)
+
+	_, err = s.run("", "default", "set", "test")
+	require.NoError(err)
+
+	out, err := s.run("", "default", "get")
+	require.NoError(err)
+	require.Equal("test\n", out)
+}
+
+func TestUsage(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	_, err := s.run("")
+	require.ErrorIs(err, errUsage)
+
+	_, err = s.run("", "unknown")
+	require.ErrorIs(err, errUsage)
+
+	_, err = s.run("", "put", "--slot", "3", "--name", "test", "--secret", "GEZDGNBV")
+	require.ErrorIs(err, feitian.ErrInvalidSlot)
+}
+
+func TestProvision(t *testing.T) {
+	require := require.New(t)
+	s := newSession(t)
+
+	cfg := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(cfg, []byte("slot1:\n  name: test\n  secret: GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\nlanguage: fr\n"), 0o600)
+	require.NoError(err)
+
+	out, err := s.run("", "provision", "--dry-run", cfg)
+	require.NoError(err)
+	require.Equal("- put totp credential \"test\" into slot1\n- set language to fr\n", out)
+
+	_, err = s.run("n\n", "provision", cfg)
+	require.ErrorIs(err, errAborted)
+
+	out, err = s.run("", "--yes", "provision", cfg)
+	require.NoError(err)
+	require.Equal("Applied 2 changes\n", out)
+
+	out, err = s.run("", "--json", "provision", cfg)
+	require.NoError(err)
+	require.JSONEq(`{"actions": [], "warnings": [], "applied": false}`, out)
+}
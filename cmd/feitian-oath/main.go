@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Command feitian-oath manages the OTP credentials of FEITIAN FIDO keys.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/discovery"
+)
+
+const usage = `Usage: feitian-oath [flags] <command> [args]
+
+Commands:
+  list                                 List credentials of all slots
+  put [flags]                          Program a credential from flags or an otpauth:// URI
+  code [--slot N] NAME                 Calculate a TOTP or HOTP code
+  calculate --challenge HEX [--slot N] NAME
+                                       Calculate the response for a challenge
+  delete [--slot N] NAME               Delete a credential
+  swap                                 Swap the credentials of both slots
+  reset                                Delete all credentials
+  language get | set en|fr             Get or set the keyboard layout
+  default get [--slot N] | set [--slot N] NAME
+                                       Get or set the default credential
+  provision [--dry-run] FILE           Reconcile the key with a YAML or JSON configuration
+
+Flags:
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, openCard); err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+type opener func(reader, id string) (*feitian.Card, func(), error)
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, open opener) error {
+	flags := flag.NewFlagSet("feitian-oath", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	reader := flags.String("reader", "", "Name of the smart card reader (default: the only connected key)")
+	id := flags.String("id", "", "Device ID of the key (default: the only connected key)")
+	jsonOutput := flags.Bool("json", false, "Print output as JSON")
+	yes := flags.Bool("yes", false, "Do not ask for confirmation of destructive operations")
+	trace := flags.Bool("trace", false, "Log all commands exchanged with the key with secrets redacted")
+	experimentalTouch := flags.Bool("experimental-touch", false, "Allow credentials which require a touch (the encoding has not been verified against a key)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n", flags.Arg(0))
+		flags.Usage()
+		return errUsage
+	}
+
+	card, closeCard, err := open(*reader, *id)
+	if err != nil {
+		return err
+	}
+	defer closeCard()
+
+	card.ExperimentalTouch = *experimentalTouch
+	card.TouchPrompt = func() {
+		fmt.Fprintln(stderr, "Touch the button of your key...")
+	}
+
+	if *trace {
+		card.Tracer = feitian.SlogTracer(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+		})))
+	}
+
+	a := &app{
+		card:  card,
+		in:    stdin,
+		out:   stdout,
+		err:   stderr,
+		json:  *jsonOutput,
+		yes:   *yes,
+		flags: flags,
+	}
+
+	return cmd(a, flags.Args()[1:])
+}
+
+func openCard(reader, id string) (*feitian.Card, func(), error) {
+	conn, err := discovery.NewPCSC(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+
+	var card *discovery.Card
+
+	switch {
+	case reader != "":
+		card, err = discovery.OpenReader(ctx, conn, reader)
+	case id != "":
+		card, err = discovery.OpenID(ctx, conn, id)
+	default:
+		card, err = discovery.OpenOnly(ctx, conn)
+	}
+	if err != nil {
+		conn.Release() //nolint:errcheck
+		return nil, nil, fmt.Errorf("failed to open key: %w", err)
+	}
+
+	closeCard := func() {
+		card.Close()   //nolint:errcheck
+		conn.Release() //nolint:errcheck
+	}
+
+	return card.Card, closeCard, nil
+}
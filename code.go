@@ -5,30 +5,74 @@ package feitian
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"time"
 )
 
+// maxDigits is the maximum number of digits of a one-time password.
+// Larger values exceed the 31 bits of the truncated digest.
+const maxDigits = 9
+
+var ErrMalformedCode = errors.New("malformed code")
+
 type Code struct {
 	Digest    []byte
 	Digits    int
 	Truncated bool
+
+	// ValidFrom and ValidUntil describe the time step of TOTP codes
+	// returned by CalculateTOTP. They are zero for all other codes.
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// Validate checks whether the code can be converted into a one-time password.
+func (c Code) Validate() error {
+	if c.Digits < 1 || c.Digits > maxDigits {
+		return fmt.Errorf("%w: %d digits", ErrMalformedCode, c.Digits)
+	}
+
+	if c.Truncated {
+		if len(c.Digest) < 4 {
+			return fmt.Errorf("%w: truncated digest too short", ErrMalformedCode)
+		}
+	} else {
+		if len(c.Digest) < 1 {
+			return fmt.Errorf("%w: empty digest", ErrMalformedCode)
+		}
+
+		if o := int(c.Digest[len(c.Digest)-1] & 0xf); o+4 > len(c.Digest) {
+			return fmt.Errorf("%w: digest too short", ErrMalformedCode)
+		}
+	}
+
+	return nil
 }
 
 // OTP converts a value into a (6 or 8 digits) one-time password.
 //
+// Callers must check the code by Validate() first. This applies to all
+// codes returned by the card as the applet also answers with static
+// passwords and challenge-responses which are not one-time passwords.
+// OTP returns an empty string for codes which do not pass Validate().
+//
 // See: RFC 4226 Section 5.3 - Generating an HOTP Value: https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
 func (c Code) OTP() string {
+	if c.Validate() != nil {
+		return ""
+	}
+
 	var code uint32
 	if c.Truncated {
 		code = binary.BigEndian.Uint32(c.Digest)
-		code %= uint32(math.Pow10(c.Digits))
 	} else {
-		hl := len(c.Digest)
-		o := c.Digest[hl-1] & 0xf
+		o := c.Digest[len(c.Digest)-1] & 0xf
 		code = binary.BigEndian.Uint32(c.Digest[o:o+4]) & ^uint32(1<<31)
 	}
 
-	s := fmt.Sprintf("%08d", code)
-	return s[len(s)-c.Digits:]
+	code %= uint32(math.Pow10(c.Digits))
+
+	return fmt.Sprintf("%0*d", c.Digits, code)
 }
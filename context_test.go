@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestContextCanceled runs against the emulator as no transcript
+// of it has been recorded from a key yet.
+func TestContextCanceled(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		ctx, cancel := context.WithCancel(t.Context())
+
+		items, err := c.ListContext(ctx)
+		require.NoError(err)
+		require.Empty(items)
+
+		cancel()
+
+		// No APDU must be sent once the context has been canceled
+		c.Tracer = func(tr feitian.Trace) {
+			require.Failf("unexpected command", "%s sent with a canceled context", tr.Name())
+		}
+
+		_, err = c.ListContext(ctx)
+		require.ErrorIs(err, context.Canceled)
+
+		err = c.PutContext(ctx, feitian.Slot1, "test", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.ErrorIs(err, context.Canceled)
+
+		_, err = c.CalculateContext(ctx, feitian.Slot1, "test")
+		require.ErrorIs(err, context.Canceled)
+
+		err = c.ResetContext(ctx)
+		require.ErrorIs(err, context.Canceled)
+
+		err = c.SelectContext(ctx)
+		require.ErrorIs(err, context.Canceled)
+
+		// The card remains usable with a fresh context
+		c.Tracer = nil
+
+		items, err = c.List()
+		require.NoError(err)
+		require.Empty(items)
+	})
+}
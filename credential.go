@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
+const (
+	defaultDigits           = 6
+	maxStaticPasswordLength = 32
+	firstPrintableCharacter = 0x20
+
+	// maxDataLength is the maximum length of the put command data.
+	// FEITIAN keys only support short APDUs. See feitian.Card.Transmit().
+	maxDataLength = 0xFF
+)
+
+var (
+	ErrInvalidKind           = errors.New("invalid kind")
+	ErrInvalidStaticPassword = errors.New("invalid static password")
+)
+
+// Credential describes an OTP credential which can be programmed
+// into a slot by PutCredential.
+type Credential struct {
+	Name      string
+	Kind      Kind
+	Algorithm Algorithm
+
+	// Secret is the HMAC key of HOTP, TOTP and challenge-response
+	// credentials or the password of a StaticPassword credential.
+	Secret []byte
+
+	// Digits is the number of digits of HOTP and TOTP codes.
+	// It defaults to 6. The applet stores it for all kinds and
+	// reports it in the responses to Calculate.
+	Digits int
+
+	// Counter is the initial counter value of HOTP credentials.
+	// It must be zero for all other kinds.
+	//
+	// The applet ignores the initial counter value. PutCredential
+	// therefore advances the counter of a freshly programmed credential
+	// by calculating and discarding Counter codes. See FastForwardHOTP.
+	Counter uint32
+
+	// Touch defaults to TouchNone.
+	Touch TouchPolicy
+}
+
+// Validate checks the credential for settings which the applet does not support.
+//
+// Secrets are limited to the block size of the hash function. Longer keys
+// would need to be hashed first according to RFC 2104. Static passwords
+// are limited to 32 characters. Both limits have not been confirmed
+// against a key yet.
+func (k *Credential) Validate() error {
+	if err := checkName(k.Name); err != nil {
+		return err
+	}
+
+	h, err := k.Algorithm.Hash()
+	if err != nil {
+		return err
+	}
+
+	switch k.Kind {
+	case HOTP, TOTP, StaticPassword, ChallengeResponse:
+	default:
+		return fmt.Errorf("%w: 0x%02x", ErrInvalidKind, byte(k.Kind))
+	}
+
+	if k.Digits != 0 {
+		if err := checkDigits(k.Digits); err != nil {
+			return err
+		}
+	}
+
+	if k.Touch != TouchUnknown {
+		if _, err := k.Touch.encode(); err != nil {
+			return err
+		}
+	}
+
+	if k.Counter != 0 && k.Kind != HOTP {
+		return fmt.Errorf("%w: counter is only supported by HOTP credentials", ErrInvalidCounter)
+	} else if k.Counter != 0 && k.Touch == TouchRequired {
+		return fmt.Errorf("%w: counter can not be advanced for credentials which require a touch", ErrInvalidCounter)
+	}
+
+	if k.Kind == StaticPassword {
+		if err := checkStaticPassword(k.Secret); err != nil {
+			return err
+		}
+	} else if l, maxLen := len(k.Secret), h().BlockSize(); l == 0 || l > maxLen {
+		return fmt.Errorf("%w: length must be between 1 and %d bytes", ErrInvalidSecret, maxLen)
+	}
+
+	_, err = k.encode()
+
+	return err
+}
+
+// checkStaticPassword checks that the password can be typed by the
+// keyboard interface of the key in all supported languages.
+func checkStaticPassword(pass []byte) error {
+	if l := len(pass); l == 0 || l > maxStaticPasswordLength {
+		return fmt.Errorf("%w: length must be between 1 and %d characters", ErrInvalidStaticPassword, maxStaticPasswordLength)
+	}
+
+	for _, r := range pass {
+		if r < firstPrintableCharacter {
+			return fmt.Errorf("%w: unsupported character 0x%02x", ErrInvalidStaticPassword, r)
+		}
+
+		for _, codes := range languageCodes {
+			if !hasKeyCode(codes, r) {
+				return fmt.Errorf("%w: unsupported character %q", ErrInvalidStaticPassword, r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasKeyCode checks if a key code table contains a character.
+// Each entry consists of the character, a modifier and a key code.
+func hasKeyCode(codes []byte, r byte) bool {
+	for i := 0; i < len(codes); i += 3 {
+		if codes[i] == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PutCredential validates and programs a credential.
+//
+// The counter of HOTP credentials is advanced to k.Counter
+// which requires one additional command per counter value.
+func (c *Card) PutCredential(slot Slot, k Credential) error {
+	return c.PutCredentialContext(context.Background(), slot, k)
+}
+
+// PutCredentialContext is like PutCredential but honors the cancellation of ctx.
+func (c *Card) PutCredentialContext(ctx context.Context, slot Slot, k Credential) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	// All checks precede the first command
+	if err := checkSlot(slot); err != nil {
+		return err
+	} else if err := k.Validate(); err != nil {
+		return err
+	} else if err := c.checkTouch(k.Touch); err != nil {
+		return err
+	}
+
+	if err := c.put(ctx, slot, k); err != nil {
+		return err
+	}
+
+	if k.Kind == HOTP && k.Counter > 0 {
+		if _, err := c.fastForward(ctx, slot, k.Name, 0, uint64(k.Counter), nil); err != nil {
+			return fmt.Errorf("failed to advance counter: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// put programs a credential without validating it.
+// The caller must hold c.mu.
+func (c *Card) put(ctx context.Context, slot Slot, k Credential) error {
+	data, err := k.encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.send(ctx, &iso.CAPDU{
+		Ins:  insPut,
+		P1:   0x00,
+		P2:   byte(slot),
+		Data: data,
+	})
+	if err != nil {
+		return wrapStatus(err)
+	}
+
+	return c.updateJournal(ctx, func(j *Journal, deviceID string) error {
+		return j.put(deviceID, slot, k.Name, k.Kind)
+	})
+}
+
+// encode returns the data of the put command.
+func (k Credential) encode() ([]byte, error) {
+	if k.Digits == 0 {
+		k.Digits = defaultDigits
+	}
+
+	if k.Touch == TouchUnknown {
+		k.Touch = TouchNone
+	}
+
+	touch, err := k.Touch.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := tlv.EncodeSimple(
+		tlv.New(tagKey, byte(k.Algorithm), byte(k.Kind), byte(k.Digits), k.Secret),
+		tlv.New(tagName, k.Name),
+		tlv.New(tagTouch, touch),
+		tlv.New(tagIMF, binary.BigEndian.AppendUint32(nil, k.Counter)))
+	if err != nil {
+		return nil, err
+	} else if len(data) > maxDataLength {
+		return nil, fmt.Errorf("%w: too long for a single command", ErrInvalidSecret)
+	}
+
+	// Really weird quirk?
+	data[1]--
+
+	return data, nil
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestPutCredential runs against the emulator as no transcript
+// of it has been recorded from a key yet.
+func TestPutCredential(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		err := c.PutCredential(feitian.Slot1, feitian.Credential{
+			Name:      "test",
+			Kind:      feitian.StaticPassword,
+			Algorithm: feitian.SHA1,
+			Secret:    []byte("my static password"),
+		})
+		require.NoError(err)
+
+		err = c.PutCredential(feitian.Slot2, feitian.Credential{
+			Name:      "hotp",
+			Kind:      feitian.HOTP,
+			Algorithm: feitian.SHA256,
+			Secret:    testSecretSHA256,
+			Digits:    8,
+		})
+		require.NoError(err)
+
+		items, err := c.List()
+		require.NoError(err)
+		require.Len(items, 2)
+		require.Equal(feitian.StaticPassword, items[0].Kind)
+		require.Equal("test", items[0].Name)
+		require.Equal(feitian.HOTP, items[1].Kind)
+		require.Equal(feitian.SHA256, items[1].Algorithm)
+		require.Equal("hotp", items[1].Name)
+	})
+}
+
+func TestCredentialValidate(t *testing.T) {
+	valid := feitian.Credential{
+		Name:      "test",
+		Kind:      feitian.TOTP,
+		Algorithm: feitian.SHA1,
+		Secret:    testSecretSHA1,
+	}
+
+	require.NoError(t, valid.Validate())
+
+	for _, tc := range []struct {
+		name   string
+		modify func(k *feitian.Credential)
+		err    error
+	}{
+		{"short name", func(k *feitian.Credential) { k.Name = "abc" }, feitian.ErrNameTooShort},
+		{"unsupported algorithm", func(k *feitian.Credential) { k.Algorithm = 0x03 }, feitian.ErrUnsupportedAlgorithm},
+		{"invalid kind", func(k *feitian.Credential) { k.Kind = 0x50 }, feitian.ErrInvalidKind},
+		{"invalid digits", func(k *feitian.Credential) { k.Digits = 7 }, feitian.ErrInvalidDigits},
+		{"empty secret", func(k *feitian.Credential) { k.Secret = nil }, feitian.ErrInvalidSecret},
+		{"long SHA1 secret", func(k *feitian.Credential) { k.Secret = bytes.Repeat([]byte{1}, 65) }, feitian.ErrInvalidSecret},
+		{"long SHA256 secret", func(k *feitian.Credential) {
+			k.Algorithm = feitian.SHA256
+			k.Secret = bytes.Repeat([]byte{1}, 65)
+		}, feitian.ErrInvalidSecret},
+		{"digits of challenge-response", func(k *feitian.Credential) {
+			k.Kind = feitian.ChallengeResponse
+			k.Digits = 7
+		}, feitian.ErrInvalidDigits},
+		{"counter on TOTP", func(k *feitian.Credential) { k.Counter = 1 }, feitian.ErrInvalidCounter},
+		{"counter on static password", func(k *feitian.Credential) {
+			k.Kind = feitian.StaticPassword
+			k.Secret = []byte("password")
+			k.Counter = 1
+		}, feitian.ErrInvalidCounter},
+		{"empty static password", func(k *feitian.Credential) {
+			k.Kind = feitian.StaticPassword
+			k.Secret = nil
+		}, feitian.ErrInvalidStaticPassword},
+		{"long static password", func(k *feitian.Credential) {
+			k.Kind = feitian.StaticPassword
+			k.Secret = bytes.Repeat([]byte("a"), 33)
+		}, feitian.ErrInvalidStaticPassword},
+		{"control character in static password", func(k *feitian.Credential) {
+			k.Kind = feitian.StaticPassword
+			k.Secret = []byte("pass\rword")
+		}, feitian.ErrInvalidStaticPassword},
+		{"unsupported character in static password", func(k *feitian.Credential) {
+			k.Kind = feitian.StaticPassword
+			k.Secret = []byte("pass<word>")
+		}, feitian.ErrInvalidStaticPassword},
+		{"non-ASCII static password", func(k *feitian.Credential) {
+			k.Kind = feitian.StaticPassword
+			k.Secret = []byte("passwörd")
+		}, feitian.ErrInvalidStaticPassword},
+		{"counter with touch", func(k *feitian.Credential) {
+			k.Kind = feitian.HOTP
+			k.Counter = 1
+			k.Touch = feitian.TouchRequired
+		}, feitian.ErrInvalidCounter},
+		{"invalid touch policy", func(k *feitian.Credential) { k.Touch = 0x10 }, feitian.ErrInvalidTouchPolicy},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k := valid
+			tc.modify(&k)
+			require.ErrorIs(t, k.Validate(), tc.err)
+		})
+	}
+
+	hotp := valid
+	hotp.Kind = feitian.HOTP
+	hotp.Counter = 10
+	require.NoError(t, hotp.Validate())
+
+	long := valid
+	long.Secret = bytes.Repeat([]byte{1}, 64)
+	require.NoError(t, long.Validate())
+
+	static := valid
+	static.Kind = feitian.StaticPassword
+	static.Secret = []byte("Correct-Horse_Battery@Staple!42")
+	require.NoError(t, static.Validate())
+}
@@ -4,6 +4,7 @@
 package feitian
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -15,6 +16,14 @@ var ErrSlotNotConfigured = errors.New("slot has not credential configured")
 
 // Set active credential (Deprecated).
 func (c *Card) SetDefault(slot Slot, name string) error {
+	return c.SetDefaultContext(context.Background(), slot, name)
+}
+
+// SetDefaultContext is like SetDefault but honors the cancellation of ctx.
+func (c *Card) SetDefaultContext(ctx context.Context, slot Slot, name string) error {
+	c.mu.Lock()
+	defer c.unlock()
+
 	if err := checkName(name); err != nil {
 		return err
 	} else if err := checkSlot(slot); err != nil {
@@ -26,36 +35,38 @@ func (c *Card) SetDefault(slot Slot, name string) error {
 		return fmt.Errorf("failed to encode slot name: %w", err)
 	}
 
-	_, err = c.Send(&iso.CAPDU{
+	_, err = c.send(ctx, &iso.CAPDU{
 		Ins:  insSetDefault,
 		P1:   0x00,
 		P2:   byte(slot),
 		Data: data,
 	})
 
-	return err
+	return wrapStatus(err)
 }
 
 // Get active credential (Deprecated).
 func (c *Card) Default(slot Slot) (string, error) {
+	return c.DefaultContext(context.Background(), slot)
+}
+
+// DefaultContext is like Default but honors the cancellation of ctx.
+func (c *Card) DefaultContext(ctx context.Context, slot Slot) (string, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
 	if err := checkSlot(slot); err != nil {
 		return "", err
 	}
 
-	resp, err := c.Send(&iso.CAPDU{
+	resp, err := c.send(ctx, &iso.CAPDU{
 		Ins: insGetDefault,
 		P1:  0x00,
 		P2:  byte(slot),
 	})
 	if err != nil {
-		return "", err
-	}
-
-	if len(resp) == 0 {
-		return "", ErrSlotNotConfigured
-	} else if len(resp) < 3 {
-		return "", iso.ErrWrongLength
+		return "", wrapStatus(err)
 	}
 
-	return string(resp[3:]), nil
+	return parseDefault(resp)
 }
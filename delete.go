@@ -3,24 +3,48 @@
 
 package feitian
 
+import (
+	"context"
+	"fmt"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
 // Delete removes the configuration from a slot.
-func (c *Card) Delete(_ /*slot*/ Slot, _ /*name*/ string) error {
-	return nil
+//
+// ErrNoSuchCredential is returned if the slot does not hold a credential with the given name.
+func (c *Card) Delete(slot Slot, name string) error {
+	return c.DeleteContext(context.Background(), slot, name)
 }
 
-//     try:
-//         if slot not in list(DICT_SLOT.keys()):
-//             result_dict = {RES_SW: '%X' % SW.ERR_CUSTOMER,
-//              RES_INFO: 'slot must be in {}.'.format(list(DICT_SLOT.keys()))}
-//             return
-//         name = name.replace(' ', '')
-//         if name is None or len(name) < 4 or len(name) > 64:
-//             result_dict = {RES_SW: '%X' % SW.ERR_CUSTOMER,
-//              RES_INFO: 'name error.'}
-//             return
-//         data = Tlv(TAG.OTP_NAME, bytes(name, encoding='utf-8'))
-//         apdu = APDU.build(0, INS.OTP_DELETE, 0, slot, data)
-//         result, sw = dev.sendApdu(apdu)
-//         result_dict.update(SW.get_info(sw))
-//     finally:
-//         click.echo(str(result_dict))
+// DeleteContext is like Delete but honors the cancellation of ctx.
+func (c *Card) DeleteContext(ctx context.Context, slot Slot, name string) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if err := checkName(name); err != nil {
+		return err
+	} else if err := checkSlot(slot); err != nil {
+		return err
+	}
+
+	data, err := tlv.EncodeSimple(tlv.New(tagName, name))
+	if err != nil {
+		return fmt.Errorf("failed to encode slot name: %w", err)
+	}
+
+	_, err = c.send(ctx, &iso.CAPDU{
+		Ins:  insDelete,
+		P1:   0x00,
+		P2:   byte(slot),
+		Data: data,
+	})
+	if err != nil {
+		return wrapStatus(err)
+	}
+
+	return c.updateJournal(ctx, func(j *Journal, deviceID string) error {
+		return j.delete(deviceID, slot, name)
+	})
+}
@@ -6,11 +6,37 @@ package feitian_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
+	iso "cunicu.li/go-iso7816"
+
 	"cunicu.li/go-feitian-oath"
 )
 
+// TestDelete only checks the validation of arguments before any command is sent.
+// Deletions themselves are covered by the tests of the emulator package
+// until a transcript of them has been recorded.
 func TestDelete(t *testing.T) {
-	withCard(t, false, func(_ *testing.T, _ *feitian.Card) {
-		// require := require.New(t)
+	withCard(t, false, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		err := c.Delete(feitian.Slot1, "abc")
+		require.ErrorIs(err, feitian.ErrNameTooShort)
+
+		err = c.Delete(feitian.Slot(0x05), "test")
+		require.ErrorIs(err, feitian.ErrInvalidSlot)
 	})
 }
+
+func TestDeleteUnknown(t *testing.T) {
+	// No transcript of a failed deletion has been recorded yet.
+	// ykneo-oath reports 6984 while ISO 7816-4 suggests 6A82.
+	// Both are reported as ErrNoSuchCredential.
+	for _, sw := range []iso.Code{iso.ErrReferenceDataNotUsable, iso.ErrFileOrAppNotFound} {
+		c := newStatusCard(t, nil, sw)
+
+		err := c.Delete(feitian.Slot1, "unknown")
+		require.ErrorIs(t, err, feitian.ErrNoSuchCredential)
+		require.ErrorIs(t, err, sw)
+	}
+}
@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package discovery finds FEITIAN keys with the OTP applet
+// among all connected smart card readers.
+//
+// A key is identified either by the name of its reader or by the
+// device ID reported by the applet. It is unverified whether the ID
+// remains the same after a Reset().
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	iso "cunicu.li/go-iso7816"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+var (
+	ErrNoKey        = errors.New("no key found")
+	ErrMultipleKeys = errors.New("multiple keys found")
+)
+
+// Connector provides access to the smart card readers of a system.
+type Connector interface {
+	// Readers returns the names of all readers.
+	Readers() ([]string, error)
+
+	// Connect connects to the card in the reader.
+	Connect(reader string) (iso.PCSCCard, error)
+}
+
+// Key describes a connected key with the OTP applet.
+type Key struct {
+	feitian.DeviceInfo
+
+	// Reader is the name of the reader which holds the key.
+	Reader string
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s (ID %s, version %s)", k.Reader, k.ID, k.Version)
+}
+
+// Card is a key which has been opened by one of the Open functions.
+type Card struct {
+	*feitian.Card
+
+	Key Key
+
+	pcscCard iso.PCSCCard
+}
+
+// Close terminates the session and disconnects from the reader.
+func (c *Card) Close() error {
+	return errors.Join(
+		c.Card.Close(),
+		c.pcscCard.Close(),
+	)
+}
+
+// List returns all keys with the OTP applet ordered by the name of their reader.
+//
+// Readers without a card, cards which can not be connected
+// and cards without the OTP applet are skipped.
+func List(ctx context.Context, conn Connector) ([]Key, error) {
+	cards, err := open(ctx, conn, func(Key) bool { return true }, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []Key{}
+
+	for _, c := range cards {
+		keys = append(keys, c.Key)
+		c.Close() //nolint:errcheck
+	}
+
+	return keys, nil
+}
+
+// OpenReader opens the key in the reader with the given name.
+// The options are passed to feitian.NewCard().
+//
+// Other readers are not connected.
+func OpenReader(ctx context.Context, conn Connector, reader string, opts ...feitian.CardOption) (*Card, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c, err := connect(ctx, conn, reader, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w in reader %q: %w", ErrNoKey, reader, err)
+	}
+
+	return c, nil
+}
+
+// OpenID opens the key with the given hex-encoded device ID.
+func OpenID(ctx context.Context, conn Connector, id string, opts ...feitian.CardOption) (*Card, error) {
+	return openOne(ctx, conn, func(k Key) bool {
+		return strings.EqualFold(k.ID, id)
+	}, fmt.Sprintf("with ID %s", id), opts)
+}
+
+// OpenOnly opens the only connected key.
+//
+// It returns ErrMultipleKeys if more than one key is connected.
+func OpenOnly(ctx context.Context, conn Connector, opts ...feitian.CardOption) (*Card, error) {
+	return openOne(ctx, conn, func(Key) bool { return true }, "", opts)
+}
+
+func openOne(ctx context.Context, conn Connector, match func(Key) bool, desc string, opts []feitian.CardOption) (*Card, error) {
+	cards, err := open(ctx, conn, match, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(cards) {
+	case 0:
+		if desc != "" {
+			return nil, fmt.Errorf("%w %s", ErrNoKey, desc)
+		}
+
+		return nil, ErrNoKey
+
+	case 1:
+		return cards[0], nil
+
+	default:
+		readers := []string{}
+
+		for _, c := range cards {
+			readers = append(readers, c.Key.String())
+			c.Close() //nolint:errcheck
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrMultipleKeys, strings.Join(readers, ", "))
+	}
+}
+
+// open opens and selects the OTP applet of all keys matching the predicate.
+func open(ctx context.Context, conn Connector, match func(Key) bool, opts []feitian.CardOption) ([]*Card, error) {
+	readers, err := conn.Readers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list readers: %w", err)
+	}
+
+	slices.Sort(readers)
+
+	cards := []*Card{}
+
+	for _, reader := range readers {
+		if err := ctx.Err(); err != nil {
+			for _, c := range cards {
+				c.Close() //nolint:errcheck
+			}
+
+			return nil, err
+		}
+
+		c, err := connect(ctx, conn, reader, opts)
+		if err != nil {
+			continue
+		}
+
+		if match(c.Key) {
+			cards = append(cards, c)
+		} else {
+			c.Close() //nolint:errcheck
+		}
+	}
+
+	return cards, nil
+}
+
+func connect(ctx context.Context, conn Connector, reader string, opts []feitian.CardOption) (*Card, error) {
+	pcscCard, err := conn.Connect(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := feitian.NewCard(pcscCard, opts...)
+	if err != nil {
+		pcscCard.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	c := &Card{
+		Card:     card,
+		pcscCard: pcscCard,
+	}
+
+	if err := card.SelectContext(ctx); err != nil {
+		c.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	c.Key = Key{
+		DeviceInfo: card.DeviceInfo(),
+		Reader:     reader,
+	}
+
+	return c, nil
+}
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ebfe/scard"
+	"github.com/stretchr/testify/require"
+
+	iso "cunicu.li/go-iso7816"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/discovery"
+	"cunicu.li/go-feitian-oath/emulator"
+)
+
+var errNoCard = errors.New("no card")
+
+// connector maps reader names to cards.
+// A nil card simulates an empty reader.
+type connector map[string]iso.PCSCCard
+
+func (c connector) Readers() ([]string, error) {
+	readers := []string{}
+	for reader := range c {
+		readers = append(readers, reader)
+	}
+
+	return readers, nil
+}
+
+func (c connector) Connect(reader string) (iso.PCSCCard, error) {
+	if card := c[reader]; card != nil {
+		return card, nil
+	}
+
+	return nil, errNoCard
+}
+
+// recordingConnector records the readers which have been connected.
+type recordingConnector struct {
+	connector
+
+	connected []string
+}
+
+func (c *recordingConnector) Connect(reader string) (iso.PCSCCard, error) {
+	c.connected = append(c.connected, reader)
+
+	return c.connector.Connect(reader)
+}
+
+// otherCard is a card without the OTP applet.
+type otherCard struct {
+	*emulator.Card
+}
+
+func (otherCard) Transmit([]byte) ([]byte, error) {
+	return iso.ErrFileOrAppNotFound[:], nil
+}
+
+func TestDiscovery(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	conn := connector{
+		"Reader B": emulator.New(),
+		"Reader A": emulator.New(),
+		"Empty":    nil,
+		"Other":    otherCard{emulator.New()},
+	}
+
+	keys, err := discovery.List(ctx, conn)
+	require.NoError(err)
+	require.Len(keys, 2)
+	require.Equal("Reader A", keys[0].Reader)
+	require.Equal("Reader B", keys[1].Reader)
+	require.NotEqual(keys[0].ID, keys[1].ID)
+
+	_, err = discovery.OpenOnly(ctx, conn)
+	require.ErrorIs(err, discovery.ErrMultipleKeys)
+	require.ErrorContains(err, "Reader A")
+	require.ErrorContains(err, "Reader B")
+
+	c, err := discovery.OpenReader(ctx, conn, "Reader B")
+	require.NoError(err)
+	require.Equal(keys[1], c.Key)
+	require.Equal(keys[1].ID, c.DeviceInfo().ID)
+	require.NoError(c.Close())
+
+	c, err = discovery.OpenID(ctx, conn, strings.ToUpper(keys[0].ID))
+	require.NoError(err)
+	require.Equal("Reader A", c.Key.Reader)
+	require.NoError(c.Close())
+
+	_, err = discovery.OpenReader(ctx, conn, "Other")
+	require.ErrorIs(err, discovery.ErrNoKey)
+
+	_, err = discovery.OpenID(ctx, conn, "0011223344556677")
+	require.ErrorIs(err, discovery.ErrNoKey)
+
+	delete(conn, "Reader B")
+
+	c, err = discovery.OpenOnly(ctx, conn)
+	require.NoError(err)
+	require.Equal("Reader A", c.Key.Reader)
+	require.NoError(c.Close())
+
+	_, err = discovery.OpenOnly(ctx, connector{})
+	require.ErrorIs(err, discovery.ErrNoKey)
+}
+
+func TestOpenReaderOnly(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	conn := &recordingConnector{
+		connector: connector{
+			"Reader A": emulator.New(),
+			"Reader B": emulator.New(),
+			"Empty":    nil,
+		},
+	}
+
+	c, err := discovery.OpenReader(ctx, conn, "Reader B")
+	require.NoError(err)
+	require.Equal("Reader B", c.Key.Reader)
+	require.NoError(c.Close())
+
+	_, err = discovery.OpenReader(ctx, conn, "Empty")
+	require.ErrorIs(err, discovery.ErrNoKey)
+	require.ErrorIs(err, errNoCard)
+
+	require.Equal([]string{"Reader B", "Empty"}, conn.connected)
+}
+
+func TestRecoverPCSC(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(feitian.RecoverReconnect, discovery.RecoverPCSC(fmt.Errorf("failed to transmit CAPDU: %w", scard.ErrRemovedCard)))
+	require.Equal(feitian.RecoverReconnect, discovery.RecoverPCSC(scard.ErrResetCard))
+	require.Equal(feitian.RecoverSelect, discovery.RecoverPCSC(iso.ErrUnsupportedInstruction))
+	require.Equal(feitian.RecoverNone, discovery.RecoverPCSC(iso.ErrFileOrAppNotFound))
+}
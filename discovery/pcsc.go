@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ebfe/scard"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/drivers/pcsc"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+var _ Connector = (*PCSC)(nil)
+
+// PCSC is a Connector for the readers of the PC/SC subsystem.
+type PCSC struct {
+	Context *scard.Context
+
+	// Shared connects to the cards in shared mode so
+	// that other applications can use them concurrently.
+	Shared bool
+}
+
+// NewPCSC establishes a new PC/SC context.
+//
+// The context must be released by Release().
+func NewPCSC(shared bool) (*PCSC, error) {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish PC/SC context: %w", err)
+	}
+
+	return &PCSC{
+		Context: ctx,
+		Shared:  shared,
+	}, nil
+}
+
+// Release releases the PC/SC context.
+func (p *PCSC) Release() error {
+	return p.Context.Release()
+}
+
+// Readers implements Connector.
+func (p *PCSC) Readers() ([]string, error) {
+	readers, err := p.Context.ListReaders()
+	if err != nil && !errors.Is(err, scard.ErrNoReadersAvailable) {
+		return nil, err
+	}
+
+	return readers, nil
+}
+
+// Connect implements Connector.
+func (p *PCSC) Connect(reader string) (iso.PCSCCard, error) {
+	return pcsc.NewCard(p.Context, reader, p.Shared)
+}
+
+// RecoverPCSC is a feitian.RecoveryPolicy which extends feitian.RecoverNotSelected
+// by reconnecting to keys which have been reset or removed.
+//
+// Reconnecting to a removed key blocks until it has been inserted again.
+// See feitian.RecoverReconnect for the commands which are retried afterwards.
+func RecoverPCSC(err error) feitian.Recovery {
+	if errors.Is(err, scard.ErrResetCard) || errors.Is(err, scard.ErrRemovedCard) {
+		return feitian.RecoverReconnect
+	}
+
+	return feitian.RecoverNotSelected(err)
+}
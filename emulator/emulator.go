@@ -0,0 +1,663 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package emulator implements an in-process software emulation
+// of the OTP applet found on FEITIAN FIDO keys.
+//
+// The emulator implements the iso7816.PCSCCard interface and can
+// therefore be passed to feitian.NewCard() for testing without hardware.
+// Its behavior is modeled after the command transcripts recorded from
+// real keys which can be found in the mockdata directory of this repository.
+// Where no transcript exists, e.g. for the status words of failed commands,
+// it follows the ykneo-oath applet which the OTP applet resembles.
+package emulator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+const (
+	idLength         = 8
+	challengeLength  = 8
+	minNameLength    = 4
+	maxNameLength    = 64
+	langQuery        = 0x31
+	langCodeOffset   = 4
+	defaultSlotIndex = -1
+)
+
+//nolint:gochecknoglobals
+var version = []byte{0x01, 0x00, 0x02}
+
+var _ iso.PCSCCard = (*Card)(nil)
+
+type credential struct {
+	Name      string
+	Kind      feitian.Kind
+	Algorithm feitian.Algorithm
+	Digits    byte
+	Secret    []byte
+	Counter   uint64
+	Touch     bool
+}
+
+func (c *credential) nameList() tlv.TagValue {
+	return tlv.New(applet.TagNameList, byte(c.Kind)|byte(c.Algorithm), c.Name)
+}
+
+// Card is an emulated FEITIAN key with the OTP applet installed.
+//
+// It is safe for concurrent use.
+type Card struct {
+	// Touch is called when a credential requiring a touch is calculated.
+	// The emulator follows the unconfirmed encoding of the touch policy
+	// which is used if feitian.Card.ExperimentalTouch is set.
+	// It returns false to simulate that the button has not been touched in time.
+	// A nil Touch simulates an immediate touch.
+	Touch func() bool
+
+	mu sync.Mutex
+
+	selected bool
+	id       []byte
+
+	// The access code key and the pending challenge of a protected applet.
+	// The applet is locked until the challenge has been answered.
+	codeKey       []byte
+	codeAlgorithm feitian.Algorithm
+	challenge     []byte
+
+	slots       [2]*credential
+	defaultSlot int // index into slots or defaultSlotIndex if unset
+
+	language feitian.Language
+	appState feitian.AppState
+}
+
+// New creates a new emulated card with empty slots.
+func New() *Card {
+	c := &Card{
+		language: feitian.LangEnglish,
+		appState: feitian.ON,
+	}
+
+	c.reset()
+
+	return c
+}
+
+// Transmit implements iso7816.PCSCCard.
+func (c *Card) Transmit(cmd []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var data []byte
+
+	code := iso.ErrWrongLength
+	if capdu, err := parseCAPDU(cmd); err == nil {
+		data, code = c.handle(capdu)
+	}
+
+	return append(data, code[:]...), nil
+}
+
+// BeginTransaction implements iso7816.PCSCCard.
+func (c *Card) BeginTransaction() error {
+	return nil
+}
+
+// EndTransaction implements iso7816.PCSCCard.
+func (c *Card) EndTransaction() error {
+	return nil
+}
+
+// Close implements iso7816.PCSCCard.
+func (c *Card) Close() error {
+	return nil
+}
+
+// Base implements iso7816.PCSCCard.
+func (c *Card) Base() iso.PCSCCard {
+	return c
+}
+
+func (c *Card) handle(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	if cmd.Ins == iso.InsSelect {
+		return c.handleSelect(cmd)
+	} else if !c.selected {
+		return nil, iso.ErrUnsupportedInstruction
+	} else if cmd.Ins == applet.InsValidate {
+		return c.handleValidate(cmd)
+	} else if c.challenge != nil {
+		return nil, iso.ErrSecurityStatusNotSatisfied
+	}
+
+	switch cmd.Ins {
+	case applet.InsSetCode:
+		return c.handleSetCode(cmd)
+
+	case applet.InsReset:
+		c.reset()
+		return nil, iso.ErrSuccess
+
+	case applet.InsDelete:
+		return c.handleDelete(cmd)
+
+	case applet.InsPut:
+		return c.handlePut(cmd)
+
+	case applet.InsList:
+		return c.handleList(cmd)
+
+	case applet.InsCalculate:
+		return c.handleCalculate(cmd)
+
+	case applet.InsCalculateAll:
+		return c.handleCalculateAll(cmd)
+
+	case applet.InsLanguage:
+		return c.handleLanguage(cmd)
+
+	case applet.InsApplication:
+		return c.handleApplication(cmd)
+
+	case applet.InsSetDefault:
+		return c.handleSetDefault(cmd)
+
+	case applet.InsGetDefault:
+		return c.handleGetDefault(cmd)
+
+	case applet.InsSwapSlot:
+		c.slots[0], c.slots[1] = c.slots[1], c.slots[0]
+		if c.defaultSlot != defaultSlotIndex {
+			c.defaultSlot = 1 - c.defaultSlot
+		}
+
+		return nil, iso.ErrSuccess
+
+	default:
+		return nil, iso.ErrUnsupportedInstruction
+	}
+}
+
+func (c *Card) handleSelect(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	if cmd.P1 != 0x04 || !bytes.Equal(cmd.Data, iso.AidFeitianOTP) {
+		c.selected = false
+		return nil, iso.ErrFileOrAppNotFound
+	}
+
+	c.selected = true
+
+	tvs := []tlv.TagValue{
+		tlv.New(applet.TagVersion, version),
+		tlv.New(applet.TagName, c.id),
+	}
+
+	if c.codeKey != nil {
+		c.challenge = make([]byte, challengeLength)
+		if _, err := rand.Read(c.challenge); err != nil {
+			return nil, iso.ErrNoDiag
+		}
+
+		tvs = append(tvs,
+			tlv.New(applet.TagChallenge, c.challenge),
+			tlv.New(applet.TagAlgorithm, byte(c.codeAlgorithm)))
+	}
+
+	resp, err := tlv.EncodeSimple(tvs...)
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	return resp, iso.ErrSuccess
+}
+
+// handleSetCode sets or clears the access code key.
+// The host proves the possession of the key like in ykneo-oath.
+func (c *Card) handleSetCode(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	tvs, err := tlv.DecodeSimple(cmd.Data)
+	if err != nil {
+		return nil, iso.ErrIncorrectData
+	}
+
+	key, _, ok := tvs.Get(applet.TagKey)
+	if !ok {
+		return nil, iso.ErrIncorrectData
+	} else if len(key) == 0 {
+		c.codeKey = nil
+		return nil, iso.ErrSuccess
+	}
+
+	challenge, _, _ := tvs.Get(applet.TagChallenge)
+	response, _, _ := tvs.Get(applet.TagResponse)
+	alg := feitian.Algorithm(key[0] & 0x0F)
+
+	if expected, err := applet.HMAC(alg, key[1:], challenge); err != nil || !hmac.Equal(expected, response) {
+		return nil, iso.ErrIncorrectData
+	}
+
+	c.codeKey = key[1:]
+	c.codeAlgorithm = alg
+
+	return nil, iso.ErrSuccess
+}
+
+// handleValidate unlocks the applet by a mutual authentication.
+func (c *Card) handleValidate(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	if c.challenge == nil {
+		return nil, iso.ErrConditionsOfUseNotSatisfied
+	}
+
+	tvs, err := tlv.DecodeSimple(cmd.Data)
+	if err != nil {
+		return nil, iso.ErrIncorrectData
+	}
+
+	response, _, _ := tvs.Get(applet.TagResponse)
+	challenge, _, _ := tvs.Get(applet.TagChallenge)
+
+	if expected, err := applet.HMAC(c.codeAlgorithm, c.codeKey, c.challenge); err != nil || !hmac.Equal(expected, response) {
+		return nil, iso.ErrReferenceDataNotUsable
+	}
+
+	c.challenge = nil
+
+	resp, err := applet.HMAC(c.codeAlgorithm, c.codeKey, challenge)
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	resp, err = tlv.EncodeSimple(tlv.New(applet.TagResponse, resp))
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	return resp, iso.ErrSuccess
+}
+
+func (c *Card) handlePut(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	idx, ok := slotIndex(cmd.P2)
+	if !ok {
+		return nil, iso.ErrIncorrectParams
+	}
+
+	// The length of the key TLV is one less than its actual value.
+	// See the quirk in feitian.Card.Put().
+	data := cmd.Data
+	if len(data) < 2 || tlv.Tag(data[0]) != applet.TagKey || len(data) < 2+int(data[1])+1 {
+		return nil, iso.ErrIncorrectData
+	}
+
+	key := data[2 : 2+int(data[1])+1]
+
+	tvs, err := tlv.DecodeSimple(data[2+len(key):])
+	if err != nil || len(key) < applet.KeyHeaderLength {
+		return nil, iso.ErrIncorrectData
+	}
+
+	name, _, ok := tvs.Get(applet.TagName)
+	if !ok || len(name) < minNameLength || len(name) > maxNameLength {
+		return nil, iso.ErrIncorrectData
+	}
+
+	cred := &credential{
+		Algorithm: feitian.Algorithm(key[0]),
+		Kind:      feitian.Kind(key[1]),
+		Digits:    key[2],
+		Secret:    bytes.Clone(key[applet.KeyHeaderLength:]),
+		Name:      string(name),
+	}
+
+	if touch, _, ok := tvs.Get(applet.TagTouch); ok && len(touch) == 1 && touch[0] == applet.TouchRequired {
+		cred.Touch = true
+	}
+
+	if _, err := cred.Algorithm.Hash(); err != nil {
+		return nil, iso.ErrIncorrectData
+	}
+
+	switch cred.Kind {
+	case feitian.HOTP, feitian.TOTP, feitian.StaticPassword, feitian.ChallengeResponse:
+	default:
+		return nil, iso.ErrIncorrectData
+	}
+
+	// Just like the real key, we ignore the initial moving factor (applet.TagIMF).
+	// HOTP counters always start at zero.
+
+	c.slots[idx] = cred
+	if c.defaultSlot == idx {
+		c.defaultSlot = defaultSlotIndex
+	}
+
+	return nil, iso.ErrSuccess
+}
+
+func (c *Card) handleDelete(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	idx, ok := slotIndex(cmd.P2)
+	if !ok {
+		return nil, iso.ErrIncorrectParams
+	}
+
+	cred, code := c.lookup(idx, cmd.Data)
+	if cred == nil {
+		return nil, code
+	}
+
+	c.slots[idx] = nil
+	if c.defaultSlot == idx {
+		c.defaultSlot = defaultSlotIndex
+	}
+
+	return nil, iso.ErrSuccess
+}
+
+func (c *Card) handleList(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	var cred *credential
+
+	if feitian.Slot(cmd.P2) == feitian.SlotDefault {
+		if c.defaultSlot != defaultSlotIndex {
+			cred = c.slots[c.defaultSlot]
+		}
+	} else {
+		idx, ok := slotIndex(cmd.P2)
+		if !ok {
+			return nil, iso.ErrIncorrectParams
+		}
+
+		cred = c.slots[idx]
+	}
+
+	if cred == nil {
+		return nil, iso.ErrSuccess
+	}
+
+	// None of the recorded list responses reports the touch policy
+	resp, err := tlv.EncodeSimple(cred.nameList())
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	return resp, iso.ErrSuccess
+}
+
+func (c *Card) handleCalculate(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	idx, ok := slotIndex(cmd.P2)
+	if !ok {
+		return nil, iso.ErrIncorrectParams
+	}
+
+	tvs, err := tlv.DecodeSimple(cmd.Data)
+	if err != nil {
+		return nil, iso.ErrIncorrectData
+	}
+
+	name, _, ok := tvs.Get(applet.TagName)
+	if !ok {
+		return nil, iso.ErrIncorrectData
+	}
+
+	cred, code := c.lookupName(idx, name)
+	if cred == nil {
+		return nil, code
+	}
+
+	if cred.Touch && c.Touch != nil && !c.Touch() {
+		return nil, iso.ErrConditionsOfUseNotSatisfied
+	}
+
+	challenge, _, _ := tvs.Get(applet.TagChallenge)
+
+	tv, err := cred.calculate(challenge, cmd.P1 == 0x01)
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	resp, err := tlv.EncodeSimple(tv)
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	return resp, iso.ErrSuccess
+}
+
+func (c *Card) handleCalculateAll(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	tvs, err := tlv.DecodeSimple(cmd.Data)
+	if err != nil {
+		return nil, iso.ErrIncorrectData
+	}
+
+	challenge, _, ok := tvs.Get(applet.TagChallenge)
+	if !ok {
+		return nil, iso.ErrIncorrectData
+	}
+
+	resps := []tlv.TagValue{}
+
+	for _, cred := range c.slots {
+		if cred == nil {
+			continue
+		}
+
+		resps = append(resps, tlv.New(applet.TagName, cred.Name))
+
+		if cred.Kind != feitian.TOTP {
+			resps = append(resps, tlv.New(applet.TagNoResponse, cred.Digits))
+			continue
+		} else if cred.Touch {
+			resps = append(resps, tlv.New(applet.TagTouch, cred.Digits))
+			continue
+		}
+
+		tv, err := cred.calculate(challenge, cmd.P1 == 0x01)
+		if err != nil {
+			return nil, iso.ErrNoDiag
+		}
+
+		resps = append(resps, tv)
+	}
+
+	resp, err := tlv.EncodeSimple(resps...)
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	return resp, iso.ErrSuccess
+}
+
+func (c *Card) handleLanguage(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	if cmd.P2 == 0x01 {
+		// The key code tables differ in the modifier of the
+		// digit keys which require shift on French keyboards.
+		if len(cmd.Data) <= langCodeOffset {
+			return nil, iso.ErrIncorrectData
+		}
+
+		if cmd.Data[langCodeOffset] == 0x02 {
+			c.language = feitian.LangFrench
+		} else {
+			c.language = feitian.LangEnglish
+		}
+
+		return nil, iso.ErrSuccess
+	}
+
+	if len(cmd.Data) != 1 || cmd.Data[0] != langQuery {
+		return nil, iso.ErrIncorrectData
+	}
+
+	return []byte{byte(c.language), 0x1E}, iso.ErrSuccess
+}
+
+// handleApplication sets or gets the state of the OTP application.
+// The encoding is an unconfirmed guess of feitian.Card.SetApplicationState().
+func (c *Card) handleApplication(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	if cmd.P2 == 0x01 {
+		if len(cmd.Data) != 1 {
+			return nil, iso.ErrWrongLength
+		}
+
+		switch state := feitian.AppState(cmd.Data[0]); state {
+		case feitian.ON, feitian.OFF:
+			c.appState = state
+		default:
+			return nil, iso.ErrIncorrectData
+		}
+
+		return nil, iso.ErrSuccess
+	}
+
+	return []byte{byte(c.appState)}, iso.ErrSuccess
+}
+
+func (c *Card) handleSetDefault(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	idx, ok := slotIndex(cmd.P2)
+	if !ok {
+		return nil, iso.ErrIncorrectParams
+	}
+
+	cred, code := c.lookup(idx, cmd.Data)
+	if cred == nil {
+		return nil, code
+	}
+
+	c.defaultSlot = idx
+
+	return nil, iso.ErrSuccess
+}
+
+func (c *Card) handleGetDefault(cmd *iso.CAPDU) ([]byte, iso.Code) {
+	// The default credential can only be queried for the slot holding it
+	if feitian.Slot(cmd.P2) == feitian.SlotDefault {
+		return nil, iso.ErrWrongParams
+	}
+
+	idx, ok := slotIndex(cmd.P2)
+	if !ok {
+		return nil, iso.ErrIncorrectParams
+	} else if idx != c.defaultSlot {
+		return nil, iso.ErrSuccess
+	}
+
+	resp, err := tlv.EncodeSimple(c.slots[c.defaultSlot].nameList())
+	if err != nil {
+		return nil, iso.ErrNoDiag
+	}
+
+	return resp, iso.ErrSuccess
+}
+
+// lookup finds the credential in a slot by the name passed as a applet.TagName TLV.
+func (c *Card) lookup(idx int, data []byte) (*credential, iso.Code) {
+	tvs, err := tlv.DecodeSimple(data)
+	if err != nil {
+		return nil, iso.ErrIncorrectData
+	}
+
+	name, _, ok := tvs.Get(applet.TagName)
+	if !ok {
+		return nil, iso.ErrIncorrectData
+	}
+
+	return c.lookupName(idx, name)
+}
+
+func (c *Card) lookupName(idx int, name []byte) (*credential, iso.Code) {
+	cred := c.slots[idx]
+	if cred == nil || cred.Name != string(name) {
+		return nil, iso.ErrReferenceDataNotUsable
+	}
+
+	return cred, iso.ErrSuccess
+}
+
+func (c *Card) reset() {
+	c.slots = [2]*credential{}
+	c.defaultSlot = defaultSlotIndex
+	c.codeKey = nil
+	c.challenge = nil
+
+	// A new identifier exercises the handling of changed IDs by feitian.Card.
+	// It is unverified whether the applet changes its identifier.
+	c.id = make([]byte, idLength)
+	if _, err := rand.Read(c.id); err != nil {
+		panic("failed to generate id")
+	}
+}
+
+func (c *credential) calculate(challenge []byte, truncate bool) (tlv.TagValue, error) {
+	resp, err := applet.Calculate(byte(c.Kind), c.Algorithm, c.Secret, &c.Counter, challenge, truncate)
+	if err != nil {
+		return tlv.TagValue{}, err
+	}
+
+	if truncate && c.Kind != feitian.StaticPassword {
+		return tlv.New(applet.TagTResponse, c.Digits, resp), nil
+	}
+
+	return tlv.New(applet.TagResponse, c.Digits, resp), nil
+}
+
+func slotIndex(p2 byte) (int, bool) {
+	switch feitian.Slot(p2) {
+	case feitian.Slot1:
+		return 0, true
+	case feitian.Slot2:
+		return 1, true
+	default:
+		return 0, false
+	}
+}
+
+// parseCAPDU decodes short and extended command APDUs.
+func parseCAPDU(b []byte) (*iso.CAPDU, error) {
+	if len(b) < iso.LenHeader {
+		return nil, iso.ErrWrongLength
+	}
+
+	cmd := &iso.CAPDU{
+		Cla: b[0],
+		Ins: iso.Instruction(b[1]),
+		P1:  b[2],
+		P2:  b[3],
+	}
+
+	body := b[iso.LenHeader:]
+
+	switch {
+	case len(body) <= 1: // Case 1 & 2
+		return cmd, nil
+
+	case body[0] == 0 && len(body) >= iso.LenLCExtended: // Extended length
+		lc := int(binary.BigEndian.Uint16(body[1:3]))
+		body = body[iso.LenLCExtended:]
+
+		if len(body) < lc {
+			return nil, iso.ErrWrongLength
+		}
+
+		cmd.Data = body[:lc]
+
+	default:
+		lc := int(body[0])
+		body = body[iso.LenLCStandard:]
+
+		if len(body) < lc {
+			return nil, iso.ErrWrongLength
+		}
+
+		cmd.Data = body[:lc]
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,510 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package emulator_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	iso "cunicu.li/go-iso7816"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/emulator"
+)
+
+//nolint:gochecknoglobals
+var (
+	testSecretSHA1   = []byte("12345678901234567890")
+	testSecretSHA256 = []byte("12345678901234567890123456789012")
+)
+
+func withCard(t *testing.T, cb func(require *require.Assertions, c *feitian.Card)) {
+	require := require.New(t)
+
+	c, err := feitian.NewCard(emulator.New())
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	cb(require, c)
+
+	err = c.Close()
+	require.NoError(err)
+}
+
+func TestNotSelected(t *testing.T) {
+	require := require.New(t)
+
+	c, err := feitian.NewCard(emulator.New())
+	require.NoError(err)
+
+	_, err = c.List()
+	require.Error(err)
+}
+
+func TestPutList(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		items, err := c.List()
+		require.NoError(err)
+		require.Empty(items)
+
+		err = c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "slot2", testSecretSHA256, feitian.SHA256, feitian.HOTP, 8, 0)
+		require.NoError(err)
+
+		items, err = c.List()
+		require.NoError(err)
+		require.Equal([]feitian.ListItem{
+			{Name: "slot1", Slot: feitian.Slot1, Algorithm: feitian.SHA1, Kind: feitian.TOTP},
+			{Name: "slot2", Slot: feitian.Slot2, Algorithm: feitian.SHA256, Kind: feitian.HOTP},
+		}, items)
+	})
+}
+
+func TestTouch(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		err := c.Put(feitian.Slot1, "touch", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchRequired))
+		require.ErrorIs(err, feitian.ErrExperimentalTouch)
+
+		c.ExperimentalTouch = true
+
+		err = c.Put(feitian.Slot1, "touch", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchRequired))
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "notouch", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchNone))
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "invalid", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchUnknown))
+		require.ErrorIs(err, feitian.ErrInvalidTouchPolicy)
+
+		// The touch policy is not reported by List
+		items, err := c.List()
+		require.NoError(err)
+		require.Len(items, 2)
+		require.Equal(feitian.TouchUnknown, items[0].Touch)
+		require.Equal(feitian.TouchUnknown, items[1].Touch)
+
+		all, err := c.CalculateAll(feitian.ChallengeTOTP(time.Now(), feitian.DefaultTimeStep))
+		require.NoError(err)
+		require.Len(all, 2)
+		require.False(all[0].Calculated)
+		require.Equal(feitian.TouchRequired, all[0].Touch)
+		require.True(all[1].Calculated)
+
+		// The touch policy is only reported if enabled
+		c.ExperimentalTouch = false
+
+		all, err = c.CalculateAll(feitian.ChallengeTOTP(time.Now(), feitian.DefaultTimeStep))
+		require.NoError(err)
+		require.Equal(feitian.TouchUnknown, all[0].Touch)
+
+		_, err = c.Calculate(feitian.Slot1, "touch")
+		require.NoError(err)
+	})
+}
+
+func TestTouchTimeout(t *testing.T) {
+	e := emulator.New()
+	e.Touch = func() bool { return false }
+
+	c, err := feitian.NewCard(e)
+	require.NoError(t, err)
+
+	c.ExperimentalTouch = true
+
+	err = c.Select()
+	require.NoError(t, err)
+
+	err = c.Put(feitian.Slot1, "touch", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchRequired))
+	require.NoError(t, err)
+
+	_, err = c.Calculate(feitian.Slot1, "touch")
+	require.ErrorIs(t, err, feitian.ErrTouchTimeout)
+}
+
+func TestTouchPrompt(t *testing.T) {
+	touched := make(chan struct{})
+
+	e := emulator.New()
+	e.Touch = func() bool {
+		<-touched
+		return true
+	}
+
+	c, err := feitian.NewCard(e)
+	require.NoError(t, err)
+
+	c.ExperimentalTouch = true
+	c.TouchPromptDelay = time.Millisecond
+	c.TouchPrompt = func() {
+		close(touched)
+	}
+
+	err = c.Select()
+	require.NoError(t, err)
+
+	err = c.Put(feitian.Slot1, "touch", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchRequired))
+	require.NoError(t, err)
+
+	// The emulator only responds after the prompt has been shown
+	_, err = c.Calculate(feitian.Slot1, "touch")
+	require.NoError(t, err)
+}
+
+func TestCalculateTOTP(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		err := c.Put(feitian.Slot1, "totp", testSecretSHA1, feitian.SHA1, feitian.TOTP, 8, 0)
+		require.NoError(err)
+
+		c.Clock = func() time.Time { return time.Unix(1111111109, 0) }
+
+		code, err := c.Calculate(feitian.Slot1, "totp")
+		require.NoError(err)
+		require.Equal("07081804", code.OTP())
+		require.True(code.ValidUntil.IsZero())
+
+		code, err = c.CalculateTOTP(feitian.Slot1, "totp")
+		require.NoError(err)
+		require.Equal("07081804", code.OTP())
+		require.Equal(time.Unix(1111111110, 0), code.ValidUntil)
+
+		for _, ts := range []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond} {
+			c.Timestep = ts
+
+			_, err = c.Calculate(feitian.Slot1, "totp")
+			require.ErrorIs(err, feitian.ErrInvalidTimestep)
+
+			_, err = c.CalculateValidFor(feitian.Slot1, "totp", 0)
+			require.ErrorIs(err, feitian.ErrInvalidTimestep)
+		}
+
+		c.Timestep = feitian.DefaultTimeStep
+
+		code, err = c.CalculateWithChallenge(feitian.Slot1, "totp", feitian.ChallengeTOTP(time.Unix(59, 0), feitian.DefaultTimeStep), true)
+		require.NoError(err)
+		require.True(code.Truncated)
+		require.Equal("94287082", code.OTP())
+	})
+}
+
+func TestCalculateHOTP(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		// The initial counter is ignored just like by the real key
+		err := c.Put(feitian.Slot1, "hotp", testSecretSHA1, feitian.SHA1, feitian.HOTP, 6, 5)
+		require.NoError(err)
+
+		for _, exp := range []string{"755224", "287082", "359152", "969429"} {
+			code, err := c.Calculate(feitian.Slot1, "hotp")
+			require.NoError(err)
+			require.Equal(exp, code.OTP())
+			require.True(code.ValidFrom.IsZero())
+			require.True(code.ValidUntil.IsZero())
+		}
+	})
+}
+
+func TestPutURICounter(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		k, err := feitian.ParseURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=3")
+		require.NoError(err)
+
+		err = c.PutURI(feitian.Slot1, k)
+		require.NoError(err)
+
+		// PutURI advances the counter by calculating codes
+		code, err := c.Calculate(feitian.Slot1, "alice")
+		require.NoError(err)
+		require.Equal("969429", code.OTP())
+
+		c.ExperimentalTouch = true
+
+		err = c.PutURI(feitian.Slot2, k, feitian.WithTouch(feitian.TouchRequired))
+		require.ErrorIs(err, feitian.ErrInvalidCounter)
+	})
+}
+
+func TestCalculateChallengeResponse(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		err := c.Put(feitian.Slot2, "chalresp", testSecretSHA256, feitian.SHA256, feitian.ChallengeResponse, 6, 0)
+		require.NoError(err)
+
+		challenge, _ := hex.DecodeString("53656420757420706572737069")
+		expected, _ := hex.DecodeString("07a3df3d374c4769391a07528229b6d02d5ddbfe17db9526afa149a4185ee066") // HMAC-SHA256
+
+		code, err := c.CalculateWithChallenge(feitian.Slot2, "chalresp", challenge, false)
+		require.NoError(err)
+		require.Equal(expected, code.Digest)
+	})
+}
+
+func TestCalculateStaticPassword(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		pass := []byte("my static password")
+
+		err := c.Put(feitian.Slot1, "static", pass, feitian.SHA1, feitian.StaticPassword, 6, 0)
+		require.NoError(err)
+
+		code, err := c.CalculateWithChallenge(feitian.Slot1, "static", nil, false)
+		require.NoError(err)
+		require.Equal(pass, code.Digest)
+
+		_, err = c.CalculateWithChallenge(feitian.Slot2, "static", nil, false)
+		require.Error(err)
+	})
+}
+
+func TestCalculateAll(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		err := c.Put(feitian.Slot1, "totp", testSecretSHA1, feitian.SHA1, feitian.TOTP, 8, 0)
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "hotp", testSecretSHA1, feitian.SHA1, feitian.HOTP, 6, 0)
+		require.NoError(err)
+
+		traces := 0
+		c.Tracer = func(feitian.Trace) { traces++ }
+
+		// Without listing, the items are taken from a single command
+		items, err := c.CalculateAll(feitian.ChallengeTOTP(time.Unix(59, 0), feitian.DefaultTimeStep))
+		require.NoError(err)
+		require.Equal(1, traces)
+		require.Len(items, 2)
+		require.True(items[0].Calculated)
+		require.Equal("94287082", items[0].Code.OTP())
+		require.Equal(feitian.SlotUnknown, items[0].Slot)
+		require.Equal(feitian.TOTP, items[0].Kind)
+
+		require.False(items[1].Calculated)
+		require.Equal("hotp", items[1].Name)
+		require.Equal(feitian.SlotUnknown, items[1].Slot)
+		require.Zero(items[1].Kind)
+		require.Equal(6, items[1].Code.Digits)
+
+		items, err = c.CalculateAll(feitian.ChallengeTOTP(time.Unix(59, 0), feitian.DefaultTimeStep), feitian.WithList())
+		require.NoError(err)
+		require.Equal(4, traces)
+		require.Len(items, 2)
+		require.True(items[0].Calculated)
+		require.Equal("94287082", items[0].Code.OTP())
+		require.Equal(feitian.Slot1, items[0].Slot)
+		require.Equal(feitian.TOTP, items[0].Kind)
+		require.Equal(feitian.SHA1, items[0].Algorithm)
+		require.Equal(feitian.TouchUnknown, items[0].Touch)
+
+		require.False(items[1].Calculated)
+		require.Equal("hotp", items[1].Name)
+		require.Equal(feitian.Slot2, items[1].Slot)
+		require.Equal(feitian.HOTP, items[1].Kind)
+		require.Equal(feitian.SHA1, items[1].Algorithm)
+		require.Equal(6, items[1].Code.Digits)
+	})
+}
+
+func TestCalculateAllSlot2(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		err := c.Put(feitian.Slot2, "totp", testSecretSHA256, feitian.SHA256, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		items, err := c.CalculateAll(feitian.ChallengeTOTP(time.Unix(59, 0), feitian.DefaultTimeStep), feitian.WithList())
+		require.NoError(err)
+		require.Len(items, 1)
+		require.True(items[0].Calculated)
+		require.Equal(feitian.Slot2, items[0].Slot)
+		require.Equal(feitian.TOTP, items[0].Kind)
+		require.Equal(feitian.SHA256, items[0].Algorithm)
+	})
+}
+
+func TestDeleteResetSwap(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		id := c.DeviceInfo().ID
+
+		err := c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.Swap()
+		require.NoError(err)
+
+		items, err := c.List()
+		require.NoError(err)
+		require.Len(items, 1)
+		require.Equal(feitian.Slot2, items[0].Slot)
+
+		err = c.Delete(feitian.Slot1, "slot1")
+		require.ErrorIs(err, feitian.ErrNoSuchCredential)
+
+		err = c.Delete(feitian.Slot2, "slot1")
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.Reset()
+		require.NoError(err)
+
+		items, err = c.List()
+		require.NoError(err)
+		require.Empty(items)
+
+		// The device information is only updated by Select
+		require.Equal(id, c.DeviceInfo().ID)
+
+		err = c.Select()
+		require.NoError(err)
+		require.NotEqual(id, c.DeviceInfo().ID)
+	})
+}
+
+func TestDefault(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		_, err := c.Default(feitian.Slot1)
+		require.ErrorIs(err, feitian.ErrSlotNotConfigured)
+
+		err = c.Put(feitian.Slot2, "slot2", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.SetDefault(feitian.Slot2, "slot2")
+		require.NoError(err)
+
+		name, err := c.Default(feitian.Slot2)
+		require.NoError(err)
+		require.Equal("slot2", name)
+
+		_, err = c.Default(feitian.SlotDefault)
+		require.ErrorIs(err, iso.ErrWrongParams)
+
+		items, err := c.List()
+		require.NoError(err)
+		require.Len(items, 2)
+		require.True(items[1].IsDefault())
+		require.Equal("slot2", items[1].Name)
+	})
+}
+
+func TestAccessCode(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		err := c.SetCode("secret")
+		require.NoError(err)
+
+		err = c.Select()
+		require.NoError(err)
+		require.True(c.Locked())
+
+		_, err = c.List()
+		require.ErrorIs(err, feitian.ErrLocked)
+
+		err = c.Validate("wrong")
+		require.ErrorIs(err, feitian.ErrWrongCode)
+
+		err = c.Validate("secret")
+		require.NoError(err)
+		require.False(c.Locked())
+
+		_, err = c.List()
+		require.NoError(err)
+
+		err = c.ClearCode()
+		require.NoError(err)
+
+		err = c.Select()
+		require.NoError(err)
+		require.False(c.Locked())
+	})
+}
+
+func TestLanguage(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		lang, err := c.Language()
+		require.NoError(err)
+		require.Equal(feitian.LangEnglish, lang)
+
+		err = c.SetLanguage(feitian.LangFrench)
+		require.NoError(err)
+
+		lang, err = c.Language()
+		require.NoError(err)
+		require.Equal(feitian.LangFrench, lang)
+	})
+}
+
+func TestApplicationState(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		state, err := c.ApplicationState()
+		require.NoError(err)
+		require.Equal(feitian.ON, state)
+
+		err = c.SetApplicationState(feitian.OFF)
+		require.NoError(err)
+
+		state, err = c.ApplicationState()
+		require.NoError(err)
+		require.Equal(feitian.OFF, state)
+	})
+}
+
+func TestTrace(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		pass := []byte("my static password")
+
+		traces := []feitian.Trace{}
+		c.Tracer = func(t feitian.Trace) {
+			traces = append(traces, t)
+		}
+
+		err := c.Put(feitian.Slot1, "totp", testSecretSHA1, feitian.SHA1, feitian.TOTP, 8, 0)
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "static", pass, feitian.SHA1, feitian.StaticPassword, 6, 0)
+		require.NoError(err)
+
+		c.Clock = func() time.Time { return time.Unix(1111111109, 0) }
+
+		code, err := c.Calculate(feitian.Slot1, "totp")
+		require.NoError(err)
+
+		static, err := c.CalculateWithChallenge(feitian.Slot2, "static", nil, false)
+		require.NoError(err)
+
+		err = c.Delete(feitian.Slot1, "unknown")
+		require.ErrorIs(err, feitian.ErrNoSuchCredential)
+
+		require.Len(traces, 5)
+		require.Equal("PUT", traces[0].Name())
+		require.Equal("CALCULATE", traces[2].Name())
+		require.Equal("DELETE", traces[4].Name())
+		require.Equal(iso.ErrSuccess, traces[0].Status)
+		require.Equal(iso.ErrReferenceDataNotUsable, traces[4].Status)
+		require.NoError(traces[4].Err)
+
+		for _, tr := range traces {
+			for _, secret := range [][]byte{testSecretSHA1, pass, code.Digest, static.Digest} {
+				require.NotContains(string(tr.Data), string(secret))
+				require.NotContains(string(tr.Response), string(secret))
+			}
+		}
+
+		// Only the secrets are redacted
+		require.Equal(byte(8), traces[2].Response[2])
+		require.Len(traces[2].Response, 2+1+20)
+
+		var buf bytes.Buffer
+
+		c.Tracer = feitian.SlogTracer(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		c.TraceSecrets = true
+
+		_, err = c.CalculateWithChallenge(feitian.Slot2, "static", nil, false)
+		require.NoError(err)
+		require.Contains(buf.String(), "ins=CALCULATE")
+		require.Contains(buf.String(), "sw=9000")
+		require.Contains(buf.String(), hex.EncodeToString(pass))
+	})
+}
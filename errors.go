@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"errors"
+	"fmt"
+
+	iso "cunicu.li/go-iso7816"
+)
+
+// Errors reported by the applet via the status word of a response.
+//
+// The original iso7816.Code remains part of the error chain
+// and can be inspected with errors.Is() or errors.As().
+var (
+	ErrNoSuchCredential   = errors.New("no such credential")
+	ErrSlotOccupied       = errors.New("slot is already occupied")
+	ErrWrongData          = errors.New("applet rejected command data")
+	ErrTouchTimeout       = errors.New("timed out waiting for touch")
+	ErrLocked             = errors.New("applet is locked by an access code")
+	ErrUnsupportedCommand = errors.New("command is not supported by the applet")
+)
+
+// statusErrors maps status words to the errors of this package.
+//
+// No transcript of a failed command has been recorded from a FEITIAN key yet.
+// The mapping therefore follows the meaning of the status words in ISO 7816-4
+// and their use by the ykneo-oath applet which the OTP applet resembles.
+//
+//nolint:gochecknoglobals
+var statusErrors = map[iso.Code]error{
+	iso.ErrReferenceDataNotUsable:     ErrNoSuchCredential,   // 6984
+	iso.ErrFileOrAppNotFound:          ErrNoSuchCredential,   // 6A82
+	iso.ErrFileAlreadyExists:          ErrSlotOccupied,       // 6A89
+	iso.ErrNoSpace:                    ErrSlotOccupied,       // 6A84
+	iso.ErrIncorrectData:              ErrWrongData,          // 6A80
+	iso.ErrWrongLength:                ErrWrongData,          // 6700
+	iso.ErrSecurityStatusNotSatisfied: ErrLocked,             // 6982
+	iso.ErrIncorrectParams:            ErrInvalidSlot,        // 6A86
+	iso.ErrWrongParams:                ErrInvalidSlot,        // 6B00
+	iso.ErrUnsupportedInstruction:     ErrUnsupportedCommand, // 6D00
+}
+
+// wrapStatus wraps a status word returned by the applet
+// into one of the errors defined by this package.
+func wrapStatus(err error) error {
+	var code iso.Code
+	if !errors.As(err, &code) {
+		return err
+	}
+
+	if sErr, ok := statusErrors[code]; ok {
+		return fmt.Errorf("%w: %w", sErr, err)
+	}
+
+	return err
+}
+
+// wrapCalculateStatus is like wrapStatus but also reports ErrTouchTimeout.
+// Only the calculate command waits for a touch of the button.
+func wrapCalculateStatus(err error) error {
+	if errors.Is(err, iso.ErrConditionsOfUseNotSatisfied) { // 6985
+		return fmt.Errorf("%w: %w", ErrTouchTimeout, err)
+	}
+
+	return wrapStatus(err)
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	iso "cunicu.li/go-iso7816"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestStatusErrors checks that the operations wrap the status words
+// of failed commands. The status words are not recorded from a real key.
+func TestStatusErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sw   iso.Code
+		call func(c *feitian.Card) error
+		err  error
+	}{
+		{"put/no space", iso.ErrNoSpace, func(c *feitian.Card) error {
+			return c.Put(feitian.Slot2, "test", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		}, feitian.ErrSlotOccupied},
+		{"calculate/unknown", iso.ErrReferenceDataNotUsable, func(c *feitian.Card) error {
+			_, err := c.Calculate(feitian.Slot1, "unknown")
+			return err
+		}, feitian.ErrNoSuchCredential},
+		{"calculate/touch", iso.ErrConditionsOfUseNotSatisfied, func(c *feitian.Card) error {
+			_, err := c.Calculate(feitian.Slot1, "test")
+			return err
+		}, feitian.ErrTouchTimeout},
+		{"default/wrong parameters", iso.ErrWrongParams, func(c *feitian.Card) error {
+			_, err := c.Default(feitian.SlotDefault)
+			return err
+		}, feitian.ErrInvalidSlot},
+		{"set default/unknown", iso.ErrReferenceDataNotUsable, func(c *feitian.Card) error {
+			return c.SetDefault(feitian.Slot1, "unknown")
+		}, feitian.ErrNoSuchCredential},
+		{"swap/locked", iso.ErrSecurityStatusNotSatisfied, func(c *feitian.Card) error {
+			return c.Swap()
+		}, feitian.ErrLocked},
+		{"reset/locked", iso.ErrSecurityStatusNotSatisfied, func(c *feitian.Card) error {
+			return c.Reset()
+		}, feitian.ErrLocked},
+		{"language/wrong data", iso.ErrIncorrectData, func(c *feitian.Card) error {
+			return c.SetLanguage(feitian.LangEnglish)
+		}, feitian.ErrWrongData},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newStatusCard(t, nil, tc.sw)
+
+			err := tc.call(c)
+			require.ErrorIs(t, err, tc.err)
+			require.ErrorIs(t, err, tc.sw)
+		})
+	}
+}
+
+// TestTouchTimeoutOnlyForCalculate checks that 6985 is only
+// reported as ErrTouchTimeout by commands which wait for a touch.
+func TestTouchTimeoutOnlyForCalculate(t *testing.T) {
+	c := newStatusCard(t, nil, iso.ErrConditionsOfUseNotSatisfied)
+
+	err := c.Delete(feitian.Slot1, "test")
+	require.ErrorIs(t, err, iso.ErrConditionsOfUseNotSatisfied)
+	require.NotErrorIs(t, err, feitian.ErrTouchTimeout)
+
+	err = c.Swap()
+	require.NotErrorIs(t, err, feitian.ErrTouchTimeout)
+}
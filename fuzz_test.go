@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	iso "cunicu.li/go-iso7816"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+// selectResponse is a response to the select command recorded in the transcript of TestPut.
+//
+//nolint:gochecknoglobals
+var selectResponse = fromHex("5903010002510881afd977fe485ebc")
+
+// responseCard is a card which responds to all commands with resp
+// and the status word sw except for the select command if sel is set.
+// A zero sw is replaced by iso.ErrSuccess.
+type responseCard struct {
+	iso.PCSCCard
+
+	resp []byte
+	sel  []byte
+	sw   iso.Code
+}
+
+func (c *responseCard) Transmit(cmd []byte) ([]byte, error) {
+	resp, sw := c.resp, c.sw
+	if c.sel != nil && iso.Instruction(cmd[1]) == iso.InsSelect {
+		resp, sw = c.sel, iso.ErrSuccess
+	} else if sw == (iso.Code{}) {
+		sw = iso.ErrSuccess
+	}
+
+	return append(append([]byte{}, resp...), sw[:]...), nil
+}
+
+func (c *responseCard) BeginTransaction() error { return nil }
+func (c *responseCard) EndTransaction() error   { return nil }
+
+func newResponseCard(t *testing.T, resp []byte) *feitian.Card {
+	return newStatusCard(t, resp, iso.ErrSuccess)
+}
+
+// newStatusCard returns a selected card which responds
+// to all other commands with resp and the status word sw.
+func newStatusCard(t *testing.T, resp []byte, sw iso.Code) *feitian.Card {
+	c, err := feitian.NewCard(&responseCard{
+		resp: resp,
+		sel:  selectResponse,
+		sw:   sw,
+	})
+	require.NoError(t, err)
+
+	err = c.Select()
+	require.NoError(t, err)
+
+	return c
+}
+
+// addSeeds adds the successful responses to commands with the
+// instruction ins found in the mock traces to the seed corpus.
+func addSeeds(f *testing.F, ins iso.Instruction) {
+	fns, err := filepath.Glob("mockdata/*/*")
+	require.NoError(f, err)
+
+	for _, fn := range fns {
+		file, err := os.Open(fn)
+		require.NoError(f, err)
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			cols := strings.Fields(scanner.Text())
+			if len(cols) != 6 || cols[0] != "on" || cols[3] != "Transmit" {
+				continue
+			}
+
+			cmd, err := hex.DecodeString(cols[4])
+			require.NoError(f, err)
+
+			resp, err := hex.DecodeString(cols[5])
+			require.NoError(f, err)
+
+			if len(cmd) < 2 || iso.Instruction(cmd[1]) != ins || len(resp) < 2 {
+				continue
+			}
+
+			if code := iso.Code(resp[len(resp)-2:]); code.IsSuccess() {
+				f.Add(resp[:len(resp)-2])
+			}
+		}
+
+		require.NoError(f, file.Close())
+	}
+}
+
+func FuzzSelect(f *testing.F) {
+	addSeeds(f, iso.InsSelect)
+
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		c, err := feitian.NewCard(&responseCard{resp: resp})
+		require.NoError(t, err)
+
+		if err := c.Select(); err == nil {
+			_ = c.DeviceInfo().Version.String()
+		}
+	})
+}
+
+func FuzzList(f *testing.F) {
+	addSeeds(f, applet.InsList)
+
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		c := newResponseCard(t, resp)
+
+		_, _ = c.List()
+	})
+}
+
+func FuzzCalculate(f *testing.F) {
+	addSeeds(f, applet.InsCalculate)
+
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		c := newResponseCard(t, resp)
+
+		if code, err := c.CalculateWithChallenge(feitian.Slot1, "test", nil, false); err == nil {
+			if code.Validate() == nil {
+				require.Len(t, code.OTP(), code.Digits)
+			} else {
+				require.Empty(t, code.OTP())
+			}
+		}
+	})
+}
+
+func FuzzCalculateAll(f *testing.F) {
+	addSeeds(f, applet.InsCalculateAll)
+
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		c := newResponseCard(t, resp)
+
+		if items, err := c.CalculateAll(nil); err == nil {
+			require.LessOrEqual(t, len(items), 2)
+
+			for _, item := range items {
+				_ = item.Code.OTP()
+			}
+		}
+	})
+}
+
+func FuzzDefault(f *testing.F) {
+	addSeeds(f, applet.InsGetDefault)
+
+	f.Fuzz(func(t *testing.T, resp []byte) {
+		c := newResponseCard(t, resp)
+
+		_, _ = c.Default(feitian.Slot1)
+	})
+}
+
+func TestMalformedResponses(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		resp string
+		call func(c *feitian.Card) error
+		err  error
+	}{
+		{"list/empty name list", "5200", func(c *feitian.Card) error { _, err := c.List(); return err }, iso.ErrWrongLength},
+		{"calculate/empty response", "5500", func(c *feitian.Card) error { _, err := c.Calculate(feitian.Slot1, "test"); return err }, iso.ErrWrongLength},
+		{"calculate/missing response", "5101ff", func(c *feitian.Card) error { _, err := c.Calculate(feitian.Slot1, "test"); return err }, feitian.ErrMissingResponse},
+		{"calculate all/empty response", "510474657374 5500", func(c *feitian.Card) error { _, err := c.CalculateAll(nil); return err }, iso.ErrWrongLength},
+		{"calculate all/missing name", "55020601", func(c *feitian.Card) error { _, err := c.CalculateAll(nil); return err }, feitian.ErrMalformedResponse},
+		{"calculate all/unknown credential", "510474657374 770106", func(c *feitian.Card) error { _, err := c.CalculateAll(nil, feitian.WithList()); return err }, feitian.ErrMalformedResponse},
+		{"default/short", "0102", func(c *feitian.Card) error { _, err := c.Default(feitian.Slot1); return err }, iso.ErrWrongLength},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newResponseCard(t, fromHex(strings.ReplaceAll(tc.resp, " ", "")))
+
+			err := tc.call(c)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestMalformedCode(t *testing.T) {
+	for _, c := range []feitian.Code{
+		{Digits: 6},
+		{Digits: 6, Digest: []byte{0x0f}},
+		{Digits: 6, Digest: []byte{1, 2, 3}, Truncated: true},
+		{Digits: 0, Digest: make([]byte, 20)},
+		{Digits: 200, Digest: make([]byte, 20)},
+	} {
+		require.ErrorIs(t, c.Validate(), feitian.ErrMalformedCode)
+		require.Empty(t, c.OTP())
+	}
+
+	c := feitian.Code{Digits: 9, Digest: []byte{0x7f, 0xff, 0xff, 0xff}, Truncated: true}
+	require.NoError(t, c.Validate())
+	require.Equal(t, "147483647", c.OTP())
+}
@@ -7,13 +7,13 @@ go 1.24.1
 
 require (
 	cunicu.li/go-iso7816 v0.8.6
+	github.com/ebfe/scard v0.0.0-20241214075232-7af069cabc25
 	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/ebfe/scard v0.0.0-20241214075232-7af069cabc25 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
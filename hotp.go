@@ -0,0 +1,155 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+var (
+	ErrCounterNotFound = errors.New("counter not found")
+	ErrCounterBehind   = errors.New("target counter is behind the current counter")
+)
+
+type fastForwardOptions struct {
+	start    uint64
+	hasStart bool
+	progress func(counter, target uint64)
+}
+
+// FastForwardOption configures FastForwardHOTP.
+type FastForwardOption func(o *fastForwardOptions)
+
+// WithStartCounter sets the current counter of the credential.
+// It defaults to the counter tracked by Card.Journal or zero which is
+// the counter of a freshly programmed credential.
+func WithStartCounter(counter uint64) FastForwardOption {
+	return func(o *fastForwardOptions) {
+		o.start = counter
+		o.hasStart = true
+	}
+}
+
+// WithProgress registers a callback which is invoked after each step
+// with the current counter of the credential.
+func WithProgress(cb func(counter, target uint64)) FastForwardOption {
+	return func(o *fastForwardOptions) {
+		o.progress = cb
+	}
+}
+
+// FastForwardHOTP advances the counter of an HOTP credential to target.
+//
+// The applet ignores the initial counter value passed to Put.
+// Hence, the only way to set the counter is to calculate and discard
+// codes until the target is reached. This requires one command per
+// counter value and can take several minutes for large targets.
+//
+// The current counter of the credential is returned. It equals target on
+// success or the counter reached before an error occurred.
+func (c *Card) FastForwardHOTP(slot Slot, name string, target uint64, opts ...FastForwardOption) (uint64, error) {
+	return c.FastForwardHOTPContext(context.Background(), slot, name, target, opts...)
+}
+
+// FastForwardHOTPContext is like FastForwardHOTP but honors the cancellation of ctx.
+// A canceled operation can be resumed by passing the returned counter to WithStartCounter.
+func (c *Card) FastForwardHOTPContext(ctx context.Context, slot Slot, name string, target uint64, opts ...FastForwardOption) (uint64, error) {
+	o := fastForwardOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	if !o.hasStart && c.Journal != nil {
+		if err := c.refreshInfo(ctx); err != nil {
+			return 0, fmt.Errorf("%w: failed to read device ID: %w", ErrJournal, err)
+		}
+
+		o.start, _ = c.Journal.Counter(c.info.ID, slot, name)
+	}
+
+	if target < o.start {
+		return o.start, fmt.Errorf("%w: %d < %d", ErrCounterBehind, target, o.start)
+	}
+
+	return c.fastForward(ctx, slot, name, o.start, target, o.progress)
+}
+
+// fastForward calculates and discards codes until the counter
+// of an HOTP credential advanced from start to target.
+// The caller must hold c.mu.
+func (c *Card) fastForward(ctx context.Context, slot Slot, name string, start, target uint64, progress func(counter, target uint64)) (uint64, error) {
+	if err := ValidateTimestep(c.Timestep); err != nil {
+		return start, err
+	}
+
+	// The challenge is ignored by the applet for HOTP credentials
+	challenge := ChallengeTOTP(c.Clock(), c.Timestep)
+
+	for counter := start; counter < target; counter++ {
+		if _, err := c.calculate(ctx, slot, name, challenge, false); errors.Is(err, ErrJournal) {
+			return counter + 1, err
+		} else if err != nil {
+			return counter, err
+		}
+
+		if progress != nil {
+			progress(counter+1, target)
+		}
+	}
+
+	return target, nil
+}
+
+// ResyncHOTP determines the current counter of an HOTP credential.
+//
+// It calculates a code on the key and searches the counter values
+// [start, start+window) for one which produces the same digest with the
+// given secret. As calculating a code advances the counter of the
+// credential, the returned counter is the one of the next code.
+// It is recorded in Card.Journal.
+func (c *Card) ResyncHOTP(slot Slot, name string, secret []byte, alg Algorithm, start, window uint64) (uint64, error) {
+	return c.ResyncHOTPContext(context.Background(), slot, name, secret, alg, start, window)
+}
+
+// ResyncHOTPContext is like ResyncHOTP but honors the cancellation of ctx.
+func (c *Card) ResyncHOTPContext(ctx context.Context, slot Slot, name string, secret []byte, alg Algorithm, start, window uint64) (uint64, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if err := ValidateTimestep(c.Timestep); err != nil {
+		return 0, err
+	}
+
+	// The journal is updated below once the counter is known
+	code, err := c.calculate(ctx, slot, name, ChallengeTOTP(c.Clock(), c.Timestep), false)
+	if err != nil && !errors.Is(err, ErrJournal) {
+		return 0, err
+	} else if code.Truncated {
+		return 0, ErrMalformedResponse
+	}
+
+	for counter := start; counter-start < window; counter++ {
+		digest, err := applet.HMAC(alg, secret, binary.BigEndian.AppendUint64(nil, counter))
+		if err != nil {
+			return 0, err
+		}
+
+		if bytes.Equal(digest, code.Digest) {
+			return counter + 1, c.updateJournal(ctx, func(j *Journal, deviceID string) error {
+				return j.set(deviceID, slot, name, counter+1)
+			})
+		}
+	}
+
+	return 0, fmt.Errorf("%w: searched %d counter values starting at %d", ErrCounterNotFound, window, start)
+}
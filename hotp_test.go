@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestHOTPFastForward runs against the emulator as no transcript
+// of it has been recorded from a key yet.
+func TestHOTPFastForward(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		c.Clock = func() time.Time {
+			return time.Unix(1111111109, 0)
+		}
+
+		v := vectorsHOTP[0]
+
+		err := c.Put(feitian.Slot1, v.Name, v.Secret, v.Algorithm, v.Kind, v.Digits, 0)
+		require.NoError(err)
+
+		progress := []uint64{}
+		counter, err := c.FastForwardHOTP(feitian.Slot1, v.Name, 3, feitian.WithProgress(func(counter, target uint64) {
+			require.EqualValues(3, target)
+			progress = append(progress, counter)
+		}))
+		require.NoError(err)
+		require.EqualValues(3, counter)
+		require.Equal([]uint64{1, 2, 3}, progress)
+
+		_, err = c.FastForwardHOTP(feitian.Slot1, v.Name, 2, feitian.WithStartCounter(3))
+		require.ErrorIs(err, feitian.ErrCounterBehind)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		counter, err = c.FastForwardHOTPContext(ctx, feitian.Slot1, v.Name, 5, feitian.WithStartCounter(3))
+		require.ErrorIs(err, context.Canceled)
+		require.EqualValues(3, counter)
+
+		counter, err = c.ResyncHOTP(feitian.Slot1, v.Name, v.Secret, v.Algorithm, 0, 10)
+		require.NoError(err)
+		require.EqualValues(4, counter)
+
+		code, err := c.Calculate(feitian.Slot1, v.Name)
+		require.NoError(err)
+		require.Equal(vectorsHOTP[4].Code, code.OTP())
+
+		_, err = c.ResyncHOTP(feitian.Slot1, v.Name, v.Secret, v.Algorithm, 0, 3)
+		require.ErrorIs(err, feitian.ErrCounterNotFound)
+	})
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// Version is the version of the OTP applet.
+type Version struct {
+	Major uint8
+	Minor uint8
+	Patch uint8
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is
+// older, equal or newer than w.
+func (v Version) Compare(w Version) int {
+	if r := cmp.Compare(v.Major, w.Major); r != 0 {
+		return r
+	} else if r := cmp.Compare(v.Minor, w.Minor); r != 0 {
+		return r
+	}
+
+	return cmp.Compare(v.Patch, w.Patch)
+}
+
+// DeviceInfo contains the information reported by the applet in response to Select().
+type DeviceInfo struct {
+	// Version of the OTP applet.
+	Version Version
+
+	// ID is the hex-encoded identifier of the applet instance.
+	ID string
+}
+
+// DeviceInfo returns the information which has been reported by the applet during the last call to Select().
+// It does not communicate with the applet.
+func (c *Card) DeviceInfo() DeviceInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.info
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// TestDeviceInfo parses the response to the select command
+// which has been recorded in the transcript of TestPut.
+func TestDeviceInfo(t *testing.T) {
+	require := require.New(t)
+
+	c := newResponseCard(t, nil)
+
+	info := c.DeviceInfo()
+	require.Equal(feitian.Version{Major: 1, Minor: 0, Patch: 2}, info.Version)
+	require.Equal("1.0.2", info.Version.String())
+	require.Equal("81afd977fe485ebc", info.ID)
+}
+
+func TestVersionCompare(t *testing.T) {
+	require := require.New(t)
+
+	v := feitian.Version{Major: 1, Minor: 0, Patch: 2}
+
+	require.Equal(0, v.Compare(feitian.Version{Major: 1, Minor: 0, Patch: 2}))
+	require.Equal(-1, v.Compare(feitian.Version{Major: 1, Minor: 0, Patch: 3}))
+	require.Equal(-1, v.Compare(feitian.Version{Major: 1, Minor: 1, Patch: 0}))
+	require.Equal(-1, v.Compare(feitian.Version{Major: 2, Minor: 0, Patch: 0}))
+	require.Equal(1, v.Compare(feitian.Version{Major: 1, Minor: 0, Patch: 1}))
+	require.Equal(1, v.Compare(feitian.Version{Major: 0, Minor: 9, Patch: 9}))
+}
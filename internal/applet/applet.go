@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package applet defines the instructions and tags of the OTP applet.
+//
+// They are shared by the card implementation, the emulator and the recorder
+// together with the calculation of the responses of credentials.
+package applet
+
+import (
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
+const (
+	TagName       tlv.Tag = 0x51
+	TagNameList   tlv.Tag = 0x52
+	TagKey        tlv.Tag = 0x53
+	TagChallenge  tlv.Tag = 0x54
+	TagResponse   tlv.Tag = 0x55
+	TagProperty   tlv.Tag = 0x58
+	TagVersion    tlv.Tag = 0x59
+	TagIMF        tlv.Tag = 0x5A // Initial Moving Factor (counter value for HOTP)
+	TagAlgorithm  tlv.Tag = 0x5B
+	TagTouch      tlv.Tag = 0x5C
+	TagTResponse  tlv.Tag = 0x76 // Truncated
+	TagNoResponse tlv.Tag = 0x77
+)
+
+const (
+	InsSetCode         iso.Instruction = 0x02
+	InsReset           iso.Instruction = 0x07
+	InsDelete          iso.Instruction = 0x08
+	InsPut             iso.Instruction = 0x09
+	InsList            iso.Instruction = 0x17
+	InsValidate        iso.Instruction = 0x19
+	InsCalculateAll    iso.Instruction = 0x1A
+	InsSendRemaining   iso.Instruction = 0x1B
+	InsCalculate       iso.Instruction = 0xA2
+	InsLanguage        iso.Instruction = 0xA7
+	InsSendRemainingFT iso.Instruction = 0xC0
+	InsApplication     iso.Instruction = 0xE1
+	InsSetDefault      iso.Instruction = 0xE5
+	InsGetDefault      iso.Instruction = 0xE6
+	InsSwapSlot        iso.Instruction = 0xE7
+)
+
+// Values of the TagTouch TLV in put and list commands.
+// TouchNone is the value which has always been sent by the vendor tool.
+// TouchRequired has not been confirmed by a recorded transcript yet.
+// It is only used if feitian.Card.ExperimentalTouch is set.
+const (
+	TouchNone     byte = 0x5C
+	TouchRequired byte = 0x01
+)
+
+// KeyHeaderLength is the length of the algorithm, kind and
+// digits which precede the secret in the value of TagKey.
+const KeyHeaderLength = 3
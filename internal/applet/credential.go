@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package applet
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+)
+
+// Kinds of credentials as encoded in the value of TagKey.
+const (
+	KindHOTP              = 0x10
+	KindTOTP              = 0x20
+	KindStaticPassword    = 0x30
+	KindChallengeResponse = 0x40
+)
+
+// Algorithm provides the hash function of a credential.
+// It is implemented by feitian.Algorithm.
+type Algorithm interface {
+	Hash() (func() hash.Hash, error)
+}
+
+// HMAC returns the HMAC of data keyed with key.
+func HMAC(alg Algorithm, key, data []byte) ([]byte, error) {
+	h, err := alg.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(h, key)
+	mac.Write(data)
+
+	return mac.Sum(nil), nil
+}
+
+// Calculate returns the response of a credential to a calculate
+// command without the preceding number of digits.
+//
+// Static passwords are returned as is. HOTP credentials use their
+// counter instead of the challenge and increment it afterwards.
+// All other kinds are calculated as HMAC over the challenge.
+// If truncate is set, the digest is truncated according to RFC 4226.
+func Calculate(kind byte, alg Algorithm, secret []byte, counter *uint64, challenge []byte, truncate bool) ([]byte, error) {
+	switch kind {
+	case KindStaticPassword:
+		return secret, nil
+
+	case KindHOTP:
+		challenge = binary.BigEndian.AppendUint64(nil, *counter)
+		*counter++
+	}
+
+	digest, err := HMAC(alg, secret, challenge)
+	if err != nil || !truncate {
+		return digest, err
+	}
+
+	o := digest[len(digest)-1] & 0xf
+	code := binary.BigEndian.Uint32(digest[o:o+4]) & ^uint32(1<<31)
+
+	return binary.BigEndian.AppendUint32(nil, code), nil
+}
@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package applet
+
+import (
+	"slices"
+
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
+// Values returns the values of the given tags in the SIMPLE-TLV encoded data.
+// The returned slices share the memory of data so that values can be
+// replaced in place. An error is returned if data is not well-formed,
+// e.g. if it is a part of a chained response.
+func Values(data []byte, tags ...tlv.Tag) ([][]byte, error) {
+	tvs, err := tlv.DecodeSimple(data)
+	if err != nil {
+		return nil, err
+	}
+
+	vs := [][]byte{}
+
+	for _, tv := range tvs {
+		if slices.Contains(tags, tv.Tag) {
+			vs = append(vs, tv.Value)
+		}
+	}
+
+	return vs, nil
+}
@@ -0,0 +1,343 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+var ErrJournal = errors.New("failed to update journal")
+
+// JournalKey identifies an HOTP credential in a Journal.
+type JournalKey struct {
+	DeviceID string // See DeviceInfo.ID
+	Slot     Slot
+	Name     string
+}
+
+// JournalEntry is the counter of a credential as tracked by a Journal.
+type JournalEntry struct {
+	JournalKey
+
+	// Counter is the counter value of the next code calculated by the key.
+	Counter uint64
+}
+
+// JournalStore persists the entries of a Journal.
+type JournalStore interface {
+	Load() ([]JournalEntry, error)
+	Save(entries []JournalEntry) error
+}
+
+// Journal tracks the counters of HOTP credentials on the host.
+//
+// The applet never reports the counter of an HOTP credential.
+// Once assigned to Card.Journal, the journal records the counter of each
+// HOTP credential programmed by Put and advances it for each calculated code.
+// Deleting, swapping and resetting slots is reflected as well.
+//
+// Changes are written to the store immediately. Errors of the store are
+// returned by the card operation which caused the change and wrap ErrJournal.
+// The operation itself has already been completed by the key in this case.
+type Journal struct {
+	mu      sync.Mutex
+	store   JournalStore
+	entries map[JournalKey]uint64
+}
+
+// NewJournal creates a journal and loads its entries from the store.
+func NewJournal(store JournalStore) (*Journal, error) {
+	entries, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load journal: %w", err)
+	}
+
+	j := &Journal{
+		store:   store,
+		entries: map[JournalKey]uint64{},
+	}
+
+	for _, e := range entries {
+		j.entries[e.JournalKey] = e.Counter
+	}
+
+	return j, nil
+}
+
+// Counter returns the counter value of the next code of a credential.
+// It returns false if the credential is not tracked by the journal.
+func (j *Journal) Counter(deviceID string, slot Slot, name string) (uint64, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	counter, ok := j.entries[JournalKey{deviceID, slot, name}]
+
+	return counter, ok
+}
+
+// Entries returns all entries of the journal.
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.sorted()
+}
+
+func (j *Journal) sorted() []JournalEntry {
+	entries := []JournalEntry{}
+	for k, c := range j.entries {
+		entries = append(entries, JournalEntry{k, c})
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		ka, kb := entries[a].JournalKey, entries[b].JournalKey
+
+		if ka.DeviceID != kb.DeviceID {
+			return ka.DeviceID < kb.DeviceID
+		} else if ka.Slot != kb.Slot {
+			return ka.Slot < kb.Slot
+		}
+
+		return ka.Name < kb.Name
+	})
+
+	return entries
+}
+
+// update applies fn to the entries and saves them if fn reports a change.
+func (j *Journal) update(fn func(entries map[JournalKey]uint64) bool) error {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !fn(j.entries) {
+		return nil
+	}
+
+	if err := j.store.Save(j.sorted()); err != nil {
+		return fmt.Errorf("%w: %w", ErrJournal, err)
+	}
+
+	return nil
+}
+
+// put records a credential programmed into a slot.
+// The applet always starts HOTP counters at zero.
+func (j *Journal) put(deviceID string, slot Slot, name string, kind Kind) error {
+	return j.update(func(entries map[JournalKey]uint64) bool {
+		// The credential replaces any other in the same slot
+		for k := range entries {
+			if k.DeviceID == deviceID && k.Slot == slot {
+				delete(entries, k)
+			}
+		}
+
+		if kind == HOTP {
+			entries[JournalKey{deviceID, slot, name}] = 0
+		}
+
+		return true
+	})
+}
+
+// advance increments the counter of a tracked credential.
+func (j *Journal) advance(deviceID string, slot Slot, name string) error {
+	return j.update(func(entries map[JournalKey]uint64) bool {
+		k := JournalKey{deviceID, slot, name}
+
+		counter, ok := entries[k]
+		if ok {
+			entries[k] = counter + 1
+		}
+
+		return ok
+	})
+}
+
+// set records the counter of a credential, e.g. after a resynchronisation.
+func (j *Journal) set(deviceID string, slot Slot, name string, counter uint64) error {
+	return j.update(func(entries map[JournalKey]uint64) bool {
+		k := JournalKey{deviceID, slot, name}
+
+		old, ok := entries[k]
+		entries[k] = counter
+
+		return !ok || old != counter
+	})
+}
+
+func (j *Journal) delete(deviceID string, slot Slot, name string) error {
+	return j.update(func(entries map[JournalKey]uint64) bool {
+		k := JournalKey{deviceID, slot, name}
+
+		_, ok := entries[k]
+		delete(entries, k)
+
+		return ok
+	})
+}
+
+func (j *Journal) swap(deviceID string) error {
+	return j.update(func(entries map[JournalKey]uint64) bool {
+		swapped := map[JournalKey]uint64{}
+
+		for k, c := range entries {
+			if k.DeviceID != deviceID {
+				continue
+			}
+
+			delete(entries, k)
+
+			switch k.Slot {
+			case Slot1:
+				k.Slot = Slot2
+			case Slot2:
+				k.Slot = Slot1
+			}
+
+			swapped[k] = c
+		}
+
+		for k, c := range swapped {
+			entries[k] = c
+		}
+
+		return len(swapped) > 0
+	})
+}
+
+func (j *Journal) reset(deviceID string) error {
+	return j.update(func(entries map[JournalKey]uint64) bool {
+		changed := false
+
+		for k := range entries {
+			if k.DeviceID == deviceID {
+				delete(entries, k)
+				changed = true
+			}
+		}
+
+		return changed
+	})
+}
+
+// JSONFileStore is a JournalStore which keeps the entries in a JSON file.
+type JSONFileStore struct {
+	Path string
+}
+
+type jsonJournalEntry struct {
+	DeviceID string `json:"device_id"`
+	Slot     Slot   `json:"slot"`
+	Name     string `json:"name"`
+	Counter  uint64 `json:"counter"`
+}
+
+// Load implements JournalStore.
+// A missing file is treated as an empty journal.
+func (s *JSONFileStore) Load() ([]JournalEntry, error) {
+	buf, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var jes []jsonJournalEntry
+	if err := json.Unmarshal(buf, &jes); err != nil {
+		return nil, err
+	}
+
+	entries := []JournalEntry{}
+	for _, je := range jes {
+		entries = append(entries, JournalEntry{
+			JournalKey: JournalKey{
+				DeviceID: je.DeviceID,
+				Slot:     je.Slot,
+				Name:     je.Name,
+			},
+			Counter: je.Counter,
+		})
+	}
+
+	return entries, nil
+}
+
+// Save implements JournalStore.
+// The file is written to a temporary file which is synced to disk and
+// renamed to replace the previous file. Hence, a crash during writing
+// leaves either the previous or the new entries behind.
+func (s *JSONFileStore) Save(entries []JournalEntry) error {
+	jes := []jsonJournalEntry{}
+	for _, e := range entries {
+		jes = append(jes, jsonJournalEntry{
+			DeviceID: e.DeviceID,
+			Slot:     e.Slot,
+			Name:     e.Name,
+			Counter:  e.Counter,
+		})
+	}
+
+	buf, err := json.MarshalIndent(jes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck
+
+	if _, err := f.Write(buf); err != nil {
+		f.Close() //nolint:errcheck
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close() //nolint:errcheck
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), s.Path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(s.Path))
+}
+
+// syncDir persists the entries of a directory, e.g. after a rename.
+// Directories can not be synced on Windows where renames are durable.
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close() //nolint:errcheck
+		return err
+	}
+
+	return d.Close()
+}
@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+var errStore = errors.New("store failed")
+
+func TestJournal(t *testing.T) {
+	store := &feitian.JSONFileStore{
+		Path: filepath.Join(t.TempDir(), "journal.json"),
+	}
+
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		j, err := feitian.NewJournal(store)
+		require.NoError(err)
+		require.Empty(j.Entries())
+
+		c.Journal = j
+
+		oldID := c.DeviceInfo().ID
+
+		err = c.Reset()
+		require.NoError(err)
+
+		// The counters are tracked with the device ID read after the reset
+		err = c.Put(feitian.Slot1, "hotp", testSecretSHA1, feitian.SHA1, feitian.HOTP, 6, 0)
+		require.NoError(err)
+
+		id := c.DeviceInfo().ID
+		require.NotEqual(oldID, id)
+
+		counter, ok := j.Counter(id, feitian.Slot1, "hotp")
+		require.True(ok)
+		require.EqualValues(0, counter)
+
+		for _, exp := range []string{"755224", "287082"} {
+			code, err := c.Calculate(feitian.Slot1, "hotp")
+			require.NoError(err)
+			require.Equal(exp, code.OTP())
+		}
+
+		// Reopen the journal to simulate a restart
+		j, err = feitian.NewJournal(store)
+		require.NoError(err)
+
+		c.Journal = j
+
+		counter, ok = j.Counter(id, feitian.Slot1, "hotp")
+		require.True(ok)
+		require.EqualValues(2, counter)
+
+		err = c.Swap()
+		require.NoError(err)
+
+		_, ok = j.Counter(id, feitian.Slot1, "hotp")
+		require.False(ok)
+
+		counter, ok = j.Counter(id, feitian.Slot2, "hotp")
+		require.True(ok)
+		require.EqualValues(2, counter)
+
+		// Only HOTP credentials are tracked
+		err = c.Put(feitian.Slot1, "totp", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		_, ok = j.Counter(id, feitian.Slot1, "totp")
+		require.False(ok)
+
+		err = c.Delete(feitian.Slot2, "hotp")
+		require.NoError(err)
+
+		require.Empty(j.Entries())
+	})
+}
+
+func TestJournalResync(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		err := c.Put(feitian.Slot1, "hotp", testSecretSHA1, feitian.SHA1, feitian.HOTP, 6, 0)
+		require.NoError(err)
+
+		_, err = c.FastForwardHOTP(feitian.Slot1, "hotp", 4)
+		require.NoError(err)
+
+		// The journal is attached after the counter has been advanced
+		j, err := feitian.NewJournal(&memoryStore{})
+		require.NoError(err)
+
+		c.Journal = j
+
+		counter, err := c.ResyncHOTP(feitian.Slot1, "hotp", testSecretSHA1, feitian.SHA1, 0, 10)
+		require.NoError(err)
+		require.EqualValues(5, counter)
+
+		counter, ok := j.Counter(c.DeviceInfo().ID, feitian.Slot1, "hotp")
+		require.True(ok)
+		require.EqualValues(5, counter)
+	})
+}
+
+func TestJournalFailure(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		store := &memoryStore{}
+
+		j, err := feitian.NewJournal(store)
+		require.NoError(err)
+
+		c.Journal = j
+
+		err = c.Put(feitian.Slot1, "hotp", testSecretSHA1, feitian.SHA1, feitian.HOTP, 6, 0)
+		require.NoError(err)
+
+		store.err = errStore
+
+		// The code has been calculated by the key and must not be lost
+		code, err := c.Calculate(feitian.Slot1, "hotp")
+		require.ErrorIs(err, feitian.ErrJournal)
+		require.ErrorIs(err, errStore)
+		require.Equal("755224", code.OTP())
+
+		counter, err := c.FastForwardHOTP(feitian.Slot1, "hotp", 3, feitian.WithStartCounter(1))
+		require.ErrorIs(err, feitian.ErrJournal)
+		require.EqualValues(2, counter)
+	})
+}
+
+// memoryStore is a JournalStore which keeps the entries in memory.
+// Saving fails with err if it is set.
+type memoryStore struct {
+	entries []feitian.JournalEntry
+	err     error
+}
+
+func (s *memoryStore) Load() ([]feitian.JournalEntry, error) {
+	return s.entries, nil
+}
+
+func (s *memoryStore) Save(entries []feitian.JournalEntry) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.entries = entries
+
+	return nil
+}
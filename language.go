@@ -4,6 +4,7 @@
 package feitian
 
 import (
+	"context"
 	"errors"
 
 	iso "cunicu.li/go-iso7816"
@@ -26,29 +27,45 @@ var languageCodes = map[Language][]byte{
 
 // SetLanguage sets English and French key value codes.
 func (c *Card) SetLanguage(lang Language) error {
+	return c.SetLanguageContext(context.Background(), lang)
+}
+
+// SetLanguageContext is like SetLanguage but honors the cancellation of ctx.
+func (c *Card) SetLanguageContext(ctx context.Context, lang Language) error {
+	c.mu.Lock()
+	defer c.unlock()
+
 	codes, ok := languageCodes[lang]
 	if !ok {
 		return ErrUnsupportedLanguage
 	}
 
-	_, err := c.Send(&iso.CAPDU{
+	_, err := c.send(ctx, &iso.CAPDU{
 		Ins:  insLanguage,
 		P1:   0x00,
 		P2:   0x01,
 		Data: codes,
 	})
 
-	return err
+	return wrapStatus(err)
 }
 
 // Language returns the currently configured language.
 func (c *Card) Language() (Language, error) {
-	resp, err := c.Send(&iso.CAPDU{
+	return c.LanguageContext(context.Background())
+}
+
+// LanguageContext is like Language but honors the cancellation of ctx.
+func (c *Card) LanguageContext(ctx context.Context) (Language, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	resp, err := c.send(ctx, &iso.CAPDU{
 		Ins:  insLanguage,
 		Data: []byte{0x31},
 	})
 	if err != nil {
-		return 0, err
+		return 0, wrapStatus(err)
 	} else if len(resp) < 1 {
 		return 0, iso.ErrWrongLength
 	}
@@ -4,47 +4,66 @@
 package feitian
 
 import (
+	"context"
+
 	"cunicu.li/go-iso7816"
-	"cunicu.li/go-iso7816/encoding/tlv"
 )
 
+// ListItem describes a credential as reported by the applet.
+//
+// The applet only reports the type (kind and algorithm), the name and
+// optionally the touch policy of a credential. Other properties like
+// the number of digits or the secret itself can not be read back.
 type ListItem struct {
 	Name      string
 	Slot      Slot
 	Algorithm Algorithm
 	Kind      Kind
+	Touch     TouchPolicy
+}
+
+// IsDefault returns true if the item has been reported for SlotDefault.
+// This is the credential which is emitted via the keyboard interface.
+func (i ListItem) IsDefault() bool {
+	return i.Slot == SlotDefault
 }
 
 // List gets OTP credentials of all slots.
+//
+// The returned items are ordered by slot: Slot1, Slot2 and finally SlotDefault.
 func (c *Card) List() ([]ListItem, error) {
+	return c.ListContext(context.Background())
+}
+
+// ListContext is like List but honors the cancellation of ctx.
+func (c *Card) ListContext(ctx context.Context) ([]ListItem, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.list(ctx, Slot1, Slot2, SlotDefault)
+}
+
+// list gets the OTP credentials of the given slots.
+// The caller must hold c.mu.
+func (c *Card) list(ctx context.Context, slots ...Slot) ([]ListItem, error) {
 	items := []ListItem{}
 
-	for _, slot := range []Slot{Slot1, Slot2, SlotDefault} {
-		resp, err := c.Send(&iso7816.CAPDU{
+	for _, slot := range slots {
+		resp, err := c.send(ctx, &iso7816.CAPDU{
 			Ins: insList,
 			P1:  0x00,
 			P2:  byte(slot),
 		})
 		if err != nil {
-			return nil, err
+			return nil, wrapStatus(err)
 		}
 
-		tvs, err := tlv.DecodeSimple(resp)
+		slotItems, err := parseList(slot, resp, c.ExperimentalTouch)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, tv := range tvs {
-			v := tv.Value
-
-			item := ListItem{
-				Kind:      Kind(v[0] & 0xF0),
-				Algorithm: Algorithm(v[0] & 0x0F),
-				Name:      string(v[1:]),
-			}
-
-			items = append(items, item)
-		}
+		items = append(items, slotItems...)
 	}
 
 	return items, nil
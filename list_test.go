@@ -25,5 +25,29 @@ func TestList(t *testing.T) {
 		items, err = c.List()
 		require.NoError(err)
 		require.Len(items, 1)
+		require.Equal(feitian.Slot1, items[0].Slot)
+	})
+}
+
+func TestListSlots(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		err := c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "slot2", testSecretSHA256, feitian.SHA256, feitian.HOTP, 8, 0)
+		require.NoError(err)
+
+		items, err := c.List()
+		require.NoError(err)
+		require.Equal([]feitian.ListItem{
+			{Name: "slot1", Slot: feitian.Slot1, Algorithm: feitian.SHA1, Kind: feitian.TOTP},
+			{Name: "slot2", Slot: feitian.Slot2, Algorithm: feitian.SHA256, Kind: feitian.HOTP},
+		}, items)
+
+		for _, item := range items {
+			require.False(item.IsDefault())
+		}
 	})
 }
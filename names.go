@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The names are used by the command-line tool and the provisioning
+// configuration. Parsing is case-insensitive.
+
+// String returns "slot1", "slot2", "default" or "unknown".
+func (s Slot) String() string {
+	switch s {
+	case Slot1:
+		return "slot1"
+	case Slot2:
+		return "slot2"
+	case SlotDefault:
+		return "default"
+	case SlotUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("0x%02x", byte(s))
+	}
+}
+
+// ParseSlot parses the name of a slot as returned by Slot.String.
+// The slots 1 and 2 can also be given by their number.
+func ParseSlot(s string) (Slot, error) {
+	switch strings.ToLower(s) {
+	case "slot1", "1":
+		return Slot1, nil
+	case "slot2", "2":
+		return Slot2, nil
+	case "default":
+		return SlotDefault, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrInvalidSlot, s)
+	}
+}
+
+// String returns "totp", "hotp", "static" or "chalresp".
+func (k Kind) String() string {
+	switch k {
+	case TOTP:
+		return "totp"
+	case HOTP:
+		return "hotp"
+	case StaticPassword:
+		return "static"
+	case ChallengeResponse:
+		return "chalresp"
+	default:
+		return fmt.Sprintf("0x%02x", byte(k))
+	}
+}
+
+// ParseKind parses the name of a kind as returned by Kind.String.
+func ParseKind(s string) (Kind, error) {
+	switch strings.ToLower(s) {
+	case "totp":
+		return TOTP, nil
+	case "hotp":
+		return HOTP, nil
+	case "static":
+		return StaticPassword, nil
+	case "chalresp":
+		return ChallengeResponse, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedKind, s)
+	}
+}
+
+// String returns "SHA1" or "SHA256".
+func (a Algorithm) String() string {
+	switch a {
+	case SHA1:
+		return "SHA1"
+	case SHA256:
+		return "SHA256"
+	default:
+		return fmt.Sprintf("0x%02x", byte(a))
+	}
+}
+
+// ParseAlgorithm parses the name of an algorithm as returned by Algorithm.String.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch strings.ToUpper(s) {
+	case "SHA1":
+		return SHA1, nil
+	case "SHA256":
+		return SHA256, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, s)
+	}
+}
+
+// String returns "en" or "fr".
+func (l Language) String() string {
+	switch l {
+	case LangEnglish:
+		return "en"
+	case LangFrench:
+		return "fr"
+	default:
+		return fmt.Sprintf("0x%02x", byte(l))
+	}
+}
+
+// ParseLanguage parses the name of a language as returned by Language.String.
+func ParseLanguage(s string) (Language, error) {
+	switch strings.ToLower(s) {
+	case "en":
+		return LangEnglish, nil
+	case "fr":
+		return LangFrench, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, s)
+	}
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+func TestNames(t *testing.T) {
+	require := require.New(t)
+
+	for _, slot := range []feitian.Slot{feitian.Slot1, feitian.Slot2, feitian.SlotDefault} {
+		parsed, err := feitian.ParseSlot(slot.String())
+		require.NoError(err)
+		require.Equal(slot, parsed)
+	}
+
+	for _, kind := range []feitian.Kind{feitian.HOTP, feitian.TOTP, feitian.StaticPassword, feitian.ChallengeResponse} {
+		parsed, err := feitian.ParseKind(kind.String())
+		require.NoError(err)
+		require.Equal(kind, parsed)
+	}
+
+	for _, alg := range []feitian.Algorithm{feitian.SHA1, feitian.SHA256} {
+		parsed, err := feitian.ParseAlgorithm(alg.String())
+		require.NoError(err)
+		require.Equal(alg, parsed)
+	}
+
+	for _, lang := range []feitian.Language{feitian.LangEnglish, feitian.LangFrench} {
+		parsed, err := feitian.ParseLanguage(lang.String())
+		require.NoError(err)
+		require.Equal(lang, parsed)
+	}
+
+	slot, err := feitian.ParseSlot("2")
+	require.NoError(err)
+	require.Equal(feitian.Slot2, slot)
+
+	kind, err := feitian.ParseKind("TOTP")
+	require.NoError(err)
+	require.Equal(feitian.TOTP, kind)
+
+	_, err = feitian.ParseSlot("3")
+	require.ErrorIs(err, feitian.ErrInvalidSlot)
+
+	_, err = feitian.ParseSlot(feitian.SlotUnknown.String())
+	require.ErrorIs(err, feitian.ErrInvalidSlot)
+
+	_, err = feitian.ParseKind("yubico")
+	require.ErrorIs(err, feitian.ErrUnsupportedKind)
+
+	_, err = feitian.ParseAlgorithm("SHA512")
+	require.ErrorIs(err, feitian.ErrUnsupportedAlgorithm)
+
+	_, err = feitian.ParseLanguage("de")
+	require.ErrorIs(err, feitian.ErrUnsupportedLanguage)
+
+	require.Equal("0x42", feitian.Kind(0x42).String())
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package otp implements HOTP (RFC 4226) and TOTP (RFC 6238) in software.
+//
+// It uses the same algorithms, challenges and truncation as the OTP applet
+// of FEITIAN keys. Codes calculated by this package are therefore identical
+// to the ones returned by feitian.Card.Calculate() for the same credential.
+// This is useful for the server side which verifies codes generated by a key.
+package otp
+
+import (
+	"encoding/binary"
+	"time"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+// HOTP calculates the code for the given counter value.
+func HOTP(alg feitian.Algorithm, secret []byte, digits int, counter uint64) (feitian.Code, error) {
+	return calculate(alg, secret, digits, binary.BigEndian.AppendUint64(nil, counter))
+}
+
+// TOTP calculates the code for the time step containing t.
+//
+// The challenge is derived by feitian.ChallengeTOTP() just like it is
+// done by feitian.Card.Calculate().
+func TOTP(alg feitian.Algorithm, secret []byte, digits int, t time.Time, ts time.Duration) (feitian.Code, error) {
+	if err := feitian.ValidateTimestep(ts); err != nil {
+		return feitian.Code{}, err
+	}
+
+	return calculate(alg, secret, digits, feitian.ChallengeTOTP(t, ts))
+}
+
+func calculate(alg feitian.Algorithm, secret []byte, digits int, challenge []byte) (feitian.Code, error) {
+	digest, err := applet.HMAC(alg, secret, challenge)
+	if err != nil {
+		return feitian.Code{}, err
+	}
+
+	code := feitian.Code{
+		Digest: digest,
+		Digits: digits,
+	}
+
+	return code, code.Validate()
+}
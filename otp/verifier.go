@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package otp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+var (
+	ErrInvalidCode = errors.New("invalid code")
+	ErrReplayed    = errors.New("code has already been used")
+)
+
+// Verifier verifies HOTP or TOTP codes of a single credential.
+//
+// The state of the verifier is kept in Counter. Callers must persist
+// it after each successful verification to protect against replay
+// attacks across restarts. A Verifier is not safe for concurrent use.
+type Verifier struct {
+	Kind      feitian.Kind // HOTP or TOTP
+	Algorithm feitian.Algorithm
+	Secret    []byte
+	Digits    int
+
+	// Counter is the lowest counter value or time step which is accepted.
+	// It is advanced past the matching value after each successful verification.
+	Counter uint64
+
+	// LookAhead is the number of HOTP counter values after Counter
+	// which are also accepted. This allows for codes which have been
+	// generated by the key but never been verified.
+	LookAhead int
+
+	// Drift is the number of TOTP time steps before and after the
+	// current one which are also accepted. This allows for clock skew
+	// and the delay between generating and verifying a code.
+	Drift int
+
+	// Timestep defaults to feitian.DefaultTimeStep.
+	Timestep time.Duration
+
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+}
+
+// Verify checks a code and returns the HOTP counter value or
+// TOTP time step which it has been generated for.
+//
+// A code is only accepted once. Codes generated for counter values or time
+// steps prior to an already verified code are rejected with ErrReplayed.
+func (v *Verifier) Verify(code string) (uint64, error) {
+	var first, last uint64
+
+	switch v.Kind {
+	case feitian.HOTP:
+		first = v.Counter
+		last = v.Counter + uint64(max(v.LookAhead, 0)) //nolint:gosec
+
+	case feitian.TOTP:
+		step, err := v.step(v.now())
+		if err != nil {
+			return 0, err
+		}
+
+		drift := uint64(max(v.Drift, 0)) //nolint:gosec
+
+		first = step - min(step, drift)
+		last = step + drift
+
+	default:
+		return 0, fmt.Errorf("%w: %#x", feitian.ErrUnsupportedKind, byte(v.Kind))
+	}
+
+	for counter := first; counter <= last; counter++ {
+		c, err := HOTP(v.Algorithm, v.Secret, v.Digits, counter)
+		if err != nil {
+			return 0, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.OTP()), []byte(code)) != 1 {
+			continue
+		}
+
+		if counter < v.Counter {
+			return 0, ErrReplayed
+		}
+
+		v.Counter = counter + 1
+
+		return counter, nil
+	}
+
+	return 0, ErrInvalidCode
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Clock == nil {
+		return time.Now()
+	}
+
+	return v.Clock()
+}
+
+func (v *Verifier) step(t time.Time) (uint64, error) {
+	ts := v.Timestep
+	if ts == 0 {
+		ts = feitian.DefaultTimeStep
+	} else if err := feitian.ValidateTimestep(ts); err != nil {
+		return 0, err
+	}
+
+	return uint64(t.Unix() / int64(ts.Seconds())), nil //nolint:gosec
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package otp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/otp"
+)
+
+//nolint:gochecknoglobals
+var (
+	testSecret = []byte("12345678901234567890")
+
+	// RFC 4226 Appendix D
+	codesHOTP = []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+)
+
+func TestVerifyHOTP(t *testing.T) {
+	require := require.New(t)
+
+	v := &otp.Verifier{
+		Kind:      feitian.HOTP,
+		Algorithm: feitian.SHA1,
+		Secret:    testSecret,
+		Digits:    6,
+		LookAhead: 3,
+	}
+
+	counter, err := v.Verify(codesHOTP[0])
+	require.NoError(err)
+	require.EqualValues(0, counter)
+	require.EqualValues(1, v.Counter)
+
+	_, err = v.Verify(codesHOTP[0])
+	require.ErrorIs(err, otp.ErrInvalidCode)
+
+	// Within look-ahead window
+	counter, err = v.Verify(codesHOTP[4])
+	require.NoError(err)
+	require.EqualValues(4, counter)
+	require.EqualValues(5, v.Counter)
+
+	// Beyond look-ahead window
+	_, err = v.Verify(codesHOTP[9])
+	require.ErrorIs(err, otp.ErrInvalidCode)
+	require.EqualValues(5, v.Counter)
+
+	_, err = v.Verify("000000")
+	require.ErrorIs(err, otp.ErrInvalidCode)
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Unix(1111111109, 0)
+
+	v := &otp.Verifier{
+		Kind:      feitian.TOTP,
+		Algorithm: feitian.SHA1,
+		Secret:    testSecret,
+		Digits:    8,
+		Drift:     1,
+		Clock: func() time.Time {
+			return now
+		},
+	}
+
+	// RFC 6238 Appendix B, T = 0x23523EC
+	step, err := v.Verify("07081804")
+	require.NoError(err)
+	require.EqualValues(0x23523EC, step)
+
+	_, err = v.Verify("07081804")
+	require.ErrorIs(err, otp.ErrReplayed)
+
+	// The code of the next time step is accepted due to drift
+	step, err = v.Verify("14050471")
+	require.NoError(err)
+	require.EqualValues(0x23523ED, step)
+
+	// The code of the previous time step has now been superseded
+	prev, err := otp.TOTP(feitian.SHA1, testSecret, 8, now.Add(-feitian.DefaultTimeStep), feitian.DefaultTimeStep)
+	require.NoError(err)
+
+	_, err = v.Verify(prev.OTP())
+	require.ErrorIs(err, otp.ErrReplayed)
+
+	// Two time steps ahead exceeds the tolerated drift
+	next, err := otp.TOTP(feitian.SHA1, testSecret, 8, now.Add(2*feitian.DefaultTimeStep), feitian.DefaultTimeStep)
+	require.NoError(err)
+
+	_, err = v.Verify(next.OTP())
+	require.ErrorIs(err, otp.ErrInvalidCode)
+
+	now = now.Add(2 * feitian.DefaultTimeStep)
+
+	_, err = v.Verify(next.OTP())
+	require.NoError(err)
+}
+
+func TestVerifyInvalidTimestep(t *testing.T) {
+	v := &otp.Verifier{
+		Kind:      feitian.TOTP,
+		Algorithm: feitian.SHA1,
+		Secret:    testSecret,
+		Timestep:  500 * time.Millisecond,
+	}
+
+	_, err := v.Verify("287082")
+	require.ErrorIs(t, err, feitian.ErrInvalidTimestep)
+
+	_, err = otp.TOTP(feitian.SHA1, testSecret, 6, time.Now(), 0)
+	require.ErrorIs(t, err, feitian.ErrInvalidTimestep)
+}
+
+func TestVerifyUnsupportedKind(t *testing.T) {
+	v := &otp.Verifier{
+		Kind:      feitian.StaticPassword,
+		Algorithm: feitian.SHA1,
+		Secret:    testSecret,
+		Digits:    6,
+	}
+
+	_, err := v.Verify("123456")
+	require.ErrorIs(t, err, feitian.ErrUnsupportedKind)
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/otp"
+)
+
+func TestSoftwareHOTP(t *testing.T) {
+	for _, v := range vectorsHOTP {
+		t.Run(v.Name, func(t *testing.T) {
+			require := require.New(t)
+
+			code, err := otp.HOTP(v.Algorithm, v.Secret, v.Digits, uint64(v.Counter))
+			require.NoError(err)
+			require.Equal(v.Hash, code.Digest)
+			require.Equal(v.Code, code.OTP())
+		})
+	}
+}
+
+func TestSoftwareTOTP(t *testing.T) {
+	for _, v := range vectorsTOTP {
+		t.Run(v.Name, func(t *testing.T) {
+			require := require.New(t)
+
+			code, err := otp.TOTP(v.Algorithm, v.Secret, v.Digits, v.Time, feitian.DefaultTimeStep)
+			require.NoError(err)
+			require.Equal(v.Code, code.OTP())
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const uriScheme = "otpauth"
+
+var (
+	ErrInvalidURI           = errors.New("invalid otpauth URI")
+	ErrUnsupportedKind      = errors.New("unsupported kind")
+	ErrUnsupportedPeriod    = errors.New("unsupported period")
+	ErrInvalidSecret        = errors.New("invalid secret")
+	ErrInvalidCounter       = errors.New("invalid counter")
+	ErrUnsupportedParameter = errors.New("unsupported parameter")
+)
+
+// URI describes a credential in the Key URI format used by Google Authenticator
+// and most identity providers:
+//
+//	otpauth://TYPE/LABEL?PARAMETERS
+//
+// See: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+type URI struct {
+	Kind      Kind
+	Issuer    string
+	Account   string
+	Secret    []byte
+	Algorithm Algorithm
+	Digits    int
+	Counter   uint32
+}
+
+// ParseURI parses an otpauth:// URI.
+//
+// Parameters which the applet can not honour are rejected.
+// These are the SHA512 algorithm, digits other than 6 or 8
+// and periods other than DefaultTimeStep.
+func ParseURI(s string) (*URI, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+	}
+
+	if u.Scheme != uriScheme {
+		return nil, fmt.Errorf("%w: scheme must be %s", ErrInvalidURI, uriScheme)
+	}
+
+	k := &URI{
+		Algorithm: SHA1,
+		Digits:    6,
+	}
+
+	switch strings.ToLower(u.Host) {
+	case "totp":
+		k.Kind = TOTP
+	case "hotp":
+		k.Kind = HOTP
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKind, u.Host)
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		k.Issuer = strings.TrimSpace(issuer)
+		k.Account = strings.TrimSpace(account)
+	} else {
+		k.Account = label
+	}
+
+	q := u.Query()
+
+	secret := strings.ToUpper(strings.TrimRight(q.Get("secret"), "="))
+	if secret == "" {
+		return nil, fmt.Errorf("%w: missing secret", ErrInvalidSecret)
+	}
+
+	if k.Secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSecret, err)
+	}
+
+	if issuer := q.Get("issuer"); issuer != "" {
+		k.Issuer = issuer
+	}
+
+	if alg := q.Get("algorithm"); alg != "" {
+		if k.Algorithm, err = ParseAlgorithm(alg); err != nil {
+			return nil, err
+		}
+	}
+
+	if digits := q.Get("digits"); digits != "" {
+		if k.Digits, err = strconv.Atoi(digits); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDigits, err)
+		} else if err := checkDigits(k.Digits); err != nil {
+			return nil, err
+		}
+	}
+
+	if period := q.Get("period"); period != "" {
+		if k.Kind != TOTP {
+			return nil, fmt.Errorf("%w: period is only supported for TOTP", ErrUnsupportedParameter)
+		}
+
+		if p, err := strconv.Atoi(period); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedPeriod, err)
+		} else if p != int(DefaultTimeStep.Seconds()) {
+			return nil, fmt.Errorf("%w: %d seconds", ErrUnsupportedPeriod, p)
+		}
+	}
+
+	if counter := q.Get("counter"); counter != "" {
+		if k.Kind != HOTP {
+			return nil, fmt.Errorf("%w: counter is only supported for HOTP", ErrUnsupportedParameter)
+		}
+
+		c, err := strconv.ParseUint(counter, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCounter, err)
+		}
+
+		k.Counter = uint32(c)
+	}
+
+	return k, nil
+}
+
+// Name returns the credential name used by Put.
+//
+// It has the form "Issuer:Account" or just "Account" if the issuer is empty.
+func (k *URI) Name() string {
+	if k.Issuer == "" {
+		return k.Account
+	}
+
+	return k.Issuer + ":" + k.Account
+}
+
+// Marshal builds the otpauth:// URI.
+//
+// The format only describes TOTP and HOTP credentials.
+// ErrUnsupportedKind is returned for all other kinds.
+func (k *URI) Marshal() (string, error) {
+	var host string
+	switch k.Kind {
+	case HOTP:
+		host = "hotp"
+	case TOTP:
+		host = "totp"
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedKind, k.Kind)
+	}
+
+	q := url.Values{}
+	q.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(k.Secret))
+
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+
+	switch k.Algorithm {
+	case SHA1:
+		q.Set("algorithm", "SHA1")
+	case SHA256:
+		q.Set("algorithm", "SHA256")
+	}
+
+	q.Set("digits", strconv.Itoa(k.Digits))
+
+	switch k.Kind {
+	case HOTP:
+		q.Set("counter", strconv.FormatUint(uint64(k.Counter), 10))
+	case TOTP:
+		q.Set("period", strconv.Itoa(int(DefaultTimeStep.Seconds())))
+	}
+
+	u := url.URL{
+		Scheme:   uriScheme,
+		Host:     host,
+		Path:     "/" + k.Name(),
+		RawQuery: q.Encode(),
+	}
+
+	return u.String(), nil
+}
+
+// Credential returns the credential described by the URI.
+func (k *URI) Credential() Credential {
+	return Credential{
+		Name:      k.Name(),
+		Kind:      k.Kind,
+		Algorithm: k.Algorithm,
+		Secret:    k.Secret,
+		Digits:    k.Digits,
+		Counter:   k.Counter,
+	}
+}
+
+// PutURI programs the credential described by the URI.
+//
+// The counter of HOTP credentials is advanced to k.Counter. See PutCredential.
+func (c *Card) PutURI(slot Slot, k *URI, opts ...PutOption) error {
+	return c.PutURIContext(context.Background(), slot, k, opts...)
+}
+
+// PutURIContext is like PutURI but honors the cancellation of ctx.
+func (c *Card) PutURIContext(ctx context.Context, slot Slot, k *URI, opts ...PutOption) error {
+	o := putOptions{
+		touch: TouchNone,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cred := k.Credential()
+	cred.Touch = o.touch
+
+	return c.PutCredentialContext(ctx, slot, cred)
+}
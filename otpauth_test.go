@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+func TestParseURI(t *testing.T) {
+	require := require.New(t)
+
+	k, err := feitian.ParseURI("otpauth://totp/ACME%20Co:john.doe@email.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=30")
+	require.NoError(err)
+	require.Equal(&feitian.URI{
+		Kind:      feitian.TOTP,
+		Issuer:    "ACME Co",
+		Account:   "john.doe@email.com",
+		Secret:    testSecretSHA1,
+		Algorithm: feitian.SHA256,
+		Digits:    8,
+	}, k)
+	require.Equal("ACME Co:john.doe@email.com", k.Name())
+
+	k, err = feitian.ParseURI("otpauth://hotp/alice?secret=gezdgnbvgy3tqojqgezdgnbvgy3tqojq&counter=42")
+	require.NoError(err)
+	require.Equal(&feitian.URI{
+		Kind:      feitian.HOTP,
+		Account:   "alice",
+		Secret:    testSecretSHA1,
+		Algorithm: feitian.SHA1,
+		Digits:    6,
+		Counter:   42,
+	}, k)
+	require.Equal("alice", k.Name())
+}
+
+func TestParseURIInvalid(t *testing.T) {
+	for uri, expErr := range map[string]error{
+		"https://totp/alice?secret=GEZDGNBV":                             feitian.ErrInvalidURI,
+		"otpauth://motp/alice?secret=GEZDGNBV":                           feitian.ErrUnsupportedKind,
+		"otpauth://totp/alice":                                           feitian.ErrInvalidSecret,
+		"otpauth://totp/alice?secret=not-base32":                         feitian.ErrInvalidSecret,
+		"otpauth://totp/alice?secret=GEZDGNBV&algorithm=SHA512":          feitian.ErrUnsupportedAlgorithm,
+		"otpauth://totp/alice?secret=GEZDGNBV&digits=7":                  feitian.ErrInvalidDigits,
+		"otpauth://totp/alice?secret=GEZDGNBV&period=60":                 feitian.ErrUnsupportedPeriod,
+		"otpauth://totp/alice?secret=GEZDGNBV&counter=1":                 feitian.ErrUnsupportedParameter,
+		"otpauth://hotp/alice?secret=GEZDGNBV&period=30":                 feitian.ErrUnsupportedParameter,
+		"otpauth://hotp/alice?secret=GEZDGNBV&counter=-1":                feitian.ErrInvalidCounter,
+		"otpauth://hotp/alice?secret=GEZDGNBV&counter=99999999999999999": feitian.ErrInvalidCounter,
+	} {
+		_, err := feitian.ParseURI(uri)
+		require.ErrorIs(t, err, expErr, uri)
+	}
+}
+
+func TestURIMarshal(t *testing.T) {
+	require := require.New(t)
+
+	for _, k := range []*feitian.URI{
+		{Kind: feitian.TOTP, Issuer: "ACME Co", Account: "john.doe@email.com", Secret: testSecretSHA256, Algorithm: feitian.SHA256, Digits: 8},
+		{Kind: feitian.HOTP, Account: "alice", Secret: testSecretSHA1, Algorithm: feitian.SHA1, Digits: 6, Counter: 10000},
+	} {
+		s, err := k.Marshal()
+		require.NoError(err)
+
+		k2, err := feitian.ParseURI(s)
+		require.NoError(err)
+		require.Equal(k, k2)
+	}
+
+	k := &feitian.URI{Kind: feitian.HOTP, Account: "alice", Secret: testSecretSHA1, Algorithm: feitian.SHA1, Digits: 6, Counter: 1}
+	s, err := k.Marshal()
+	require.NoError(err)
+	require.Equal("otpauth://hotp/alice?algorithm=SHA1&counter=1&digits=6&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", s)
+
+	for _, kind := range []feitian.Kind{feitian.StaticPassword, feitian.ChallengeResponse} {
+		k := &feitian.URI{Kind: kind, Account: "alice", Secret: testSecretSHA1, Algorithm: feitian.SHA1, Digits: 6}
+		_, err := k.Marshal()
+		require.ErrorIs(err, feitian.ErrUnsupportedKind)
+	}
+}
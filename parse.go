@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"encoding/hex"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+)
+
+// The functions in this file decode the responses of the applet.
+// They must not trust the applet and return an error for malformed responses.
+
+// selectResponse is the decoded response to the select command.
+type selectResponse struct {
+	info          DeviceInfo
+	id            []byte
+	challenge     []byte
+	codeAlgorithm Algorithm
+}
+
+func parseSelect(resp []byte) (selectResponse, error) {
+	r := selectResponse{
+		codeAlgorithm: SHA1,
+	}
+
+	tvs, err := tlv.DecodeSimple(resp)
+	if err != nil {
+		return r, err
+	}
+
+	for _, tv := range tvs {
+		switch tv.Tag {
+		case tagVersion:
+			if len(tv.Value) < 3 {
+				return r, iso.ErrWrongLength
+			}
+
+			r.info.Version = Version{
+				Major: tv.Value[0],
+				Minor: tv.Value[1],
+				Patch: tv.Value[2],
+			}
+
+		case tagName:
+			r.id = tv.Value
+			r.info.ID = hex.EncodeToString(tv.Value)
+
+		case tagChallenge:
+			r.challenge = tv.Value
+
+		case tagAlgorithm:
+			if len(tv.Value) < 1 {
+				return r, iso.ErrWrongLength
+			}
+
+			r.codeAlgorithm = Algorithm(tv.Value[0])
+		}
+	}
+
+	return r, nil
+}
+
+// parseList decodes the response to the list command for a single slot.
+// The touch policy is only decoded if touch is set. See Card.ExperimentalTouch.
+func parseList(slot Slot, resp []byte, touch bool) ([]ListItem, error) {
+	tvs, err := tlv.DecodeSimple(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []ListItem{}
+
+	for _, tv := range tvs {
+		v := tv.Value
+
+		switch tv.Tag {
+		case tagNameList:
+			if len(v) < 1 {
+				return nil, iso.ErrWrongLength
+			}
+
+			items = append(items, ListItem{
+				Kind:      Kind(v[0] & 0xF0),
+				Algorithm: Algorithm(v[0] & 0x0F),
+				Name:      string(v[1:]),
+				Slot:      slot,
+			})
+
+		case tagTouch:
+			// None of the recorded list responses contains the touch policy.
+			// It is decoded in case other applet versions report it
+			// following the name of the credential.
+			if touch && len(items) > 0 && len(v) >= 1 {
+				items[len(items)-1].Touch = decodeTouch(v[0])
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// parseCode decodes a response value consisting of
+// the number of digits followed by the digest.
+func parseCode(tv tlv.TagValue) (Code, error) {
+	if len(tv.Value) < 1 {
+		return Code{}, iso.ErrWrongLength
+	}
+
+	return Code{
+		Digits:    int(tv.Value[0]),
+		Digest:    tv.Value[1:],
+		Truncated: tv.Tag == tagTResponse,
+	}, nil
+}
+
+// parseCalculate decodes the response to the calculate command.
+func parseCalculate(resp []byte) (Code, error) {
+	tvs, err := tlv.DecodeSimple(resp)
+	if err != nil {
+		return Code{}, err
+	}
+
+	for _, tv := range tvs {
+		switch tv.Tag {
+		case tagResponse, tagTResponse:
+			return parseCode(tv)
+		}
+	}
+
+	return Code{}, ErrMissingResponse
+}
+
+// parseCalculateAll decodes the response to the calculate all command.
+// The slot of the returned items is unknown and their kind is only set
+// for calculated TOTP credentials. See mergeCalculateAll().
+//
+// Like in ykneo-oath, tagTouch is expected instead of a response for
+// credentials which require a touch. This is unconfirmed. Hence, the touch
+// policy is only reported if touch is set. See Card.ExperimentalTouch.
+func parseCalculateAll(resp []byte, touch bool) ([]CalculateAllItem, error) {
+	tvs, err := tlv.DecodeSimple(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CalculateAllItem{}
+
+	for _, tv := range tvs {
+		if tv.Tag == tagName {
+			// The applet holds at most two credentials
+			if len(items) >= 2 {
+				return nil, ErrMalformedResponse
+			}
+
+			items = append(items, CalculateAllItem{
+				ListItem: ListItem{
+					Name: string(tv.Value),
+					Slot: SlotUnknown,
+				},
+			})
+
+			continue
+		}
+
+		if len(items) == 0 {
+			return nil, ErrMalformedResponse
+		}
+
+		item := &items[len(items)-1]
+
+		switch tv.Tag {
+		case tagResponse, tagTResponse:
+			code, err := parseCode(tv)
+			if err != nil {
+				return nil, err
+			}
+
+			// The applet only calculates TOTP credentials
+			item.Calculated = true
+			item.Kind = TOTP
+			item.Code = code
+
+		case tagNoResponse, tagTouch:
+			if touch && tv.Tag == tagTouch {
+				item.Touch = TouchRequired
+			}
+
+			if len(tv.Value) >= 1 {
+				item.Code.Digits = int(tv.Value[0])
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// parseDefault decodes the response to the get default command.
+func parseDefault(resp []byte) (string, error) {
+	if len(resp) == 0 {
+		return "", ErrSlotNotConfigured
+	} else if len(resp) < 3 {
+		return "", iso.ErrWrongLength
+	}
+
+	return string(resp[3:]), nil
+}
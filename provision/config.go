@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package provision reconciles the configuration of a key against a desired state.
+//
+// The desired state is described by a Config which is usually loaded from a
+// YAML or JSON file:
+//
+//	slot1:
+//	  name: ACME:vpn
+//	  kind: totp
+//	  secret: JBSWY3DPEHPK3PXP
+//	slot2:
+//	  name: legacy
+//	  kind: static
+//	  password: s3cret-Passw0rd
+//	language: fr
+//	default: slot2
+//	enabled: true
+//
+// NewPlan compares the desired state to the current state of a key
+// and Plan.Apply performs only the operations which are required.
+package provision
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config is the desired state of a key.
+//
+// Empty fields are left untouched on the key.
+type Config struct {
+	Slot1 *SlotConfig `json:"slot1,omitempty" yaml:"slot1,omitempty"`
+	Slot2 *SlotConfig `json:"slot2,omitempty" yaml:"slot2,omitempty"`
+
+	// DeleteUnconfigured deletes the credentials of slots
+	// which are not configured.
+	DeleteUnconfigured bool `json:"delete_unconfigured,omitempty" yaml:"delete_unconfigured,omitempty"`
+
+	Language string `json:"language,omitempty" yaml:"language,omitempty"` // en or fr
+	Default  string `json:"default,omitempty"  yaml:"default,omitempty"`  // slot1 or slot2
+	Enabled  *bool  `json:"enabled,omitempty"  yaml:"enabled,omitempty"`  // State of the OTP application
+}
+
+// SlotConfig is the desired credential of a slot.
+//
+// It is either given by an otpauth:// URI or by its individual fields.
+// The counter of HOTP URIs is reached by calculating codes after programming
+// the credential. See feitian.Card.PutCredential.
+type SlotConfig struct {
+	URI string `json:"uri,omitempty" yaml:"uri,omitempty"`
+
+	Name      string `json:"name,omitempty"      yaml:"name,omitempty"`
+	Kind      string `json:"kind,omitempty"      yaml:"kind,omitempty"`      // totp, hotp, static or chalresp
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"` // SHA1 or SHA256
+	Secret    string `json:"secret,omitempty"    yaml:"secret,omitempty"`    // Base32-encoded
+	Password  string `json:"password,omitempty"  yaml:"password,omitempty"`  // Static passwords only
+	Digits    int    `json:"digits,omitempty"    yaml:"digits,omitempty"`
+	Touch     bool   `json:"touch,omitempty"     yaml:"touch,omitempty"`
+}
+
+// Load reads a configuration in YAML or JSON format.
+func Load(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	return cfg, nil
+}
+
+// LoadFile reads a configuration file in YAML or JSON format.
+func LoadFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Credential converts the slot configuration into a credential.
+func (s *SlotConfig) Credential() (feitian.Credential, error) {
+	cred := feitian.Credential{
+		Touch: feitian.TouchNone,
+	}
+
+	if s.Touch {
+		cred.Touch = feitian.TouchRequired
+	}
+
+	if s.URI != "" {
+		k, err := feitian.ParseURI(s.URI)
+		if err != nil {
+			return cred, err
+		}
+
+		touch := cred.Touch
+		cred = k.Credential()
+		cred.Touch = touch
+
+		if s.Name != "" {
+			cred.Name = s.Name
+		}
+
+		return cred, cred.Validate()
+	}
+
+	var err error
+
+	cred.Name = s.Name
+	cred.Digits = s.Digits
+
+	cred.Kind, cred.Algorithm = feitian.TOTP, feitian.SHA1
+
+	if s.Kind != "" {
+		if cred.Kind, err = feitian.ParseKind(s.Kind); err != nil {
+			return cred, err
+		}
+	}
+
+	if s.Algorithm != "" {
+		if cred.Algorithm, err = feitian.ParseAlgorithm(s.Algorithm); err != nil {
+			return cred, err
+		}
+	}
+
+	if cred.Kind == feitian.StaticPassword {
+		cred.Secret = []byte(s.Password)
+	} else {
+		secret := strings.ToUpper(strings.TrimRight(s.Secret, "="))
+		if cred.Secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+			return cred, fmt.Errorf("%w: %w", feitian.ErrInvalidSecret, err)
+		}
+	}
+
+	return cred, cred.Validate()
+}
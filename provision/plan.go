@@ -0,0 +1,270 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package provision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cunicu.li/go-feitian-oath"
+)
+
+// Action is a single operation of a Plan.
+type Action struct {
+	Description string
+
+	apply func(ctx context.Context, c *feitian.Card) error
+}
+
+func (a Action) String() string {
+	return a.Description
+}
+
+// Plan is the list of operations required to bring a key into the desired state.
+type Plan struct {
+	Actions []Action
+
+	// Warnings describe differences which can not be detected
+	// as the key does not report the required information.
+	Warnings []string
+}
+
+// Empty returns true if the key already is in the desired state.
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+func (p *Plan) String() string {
+	var b strings.Builder
+
+	if p.Empty() {
+		b.WriteString("No changes required\n")
+	}
+
+	for _, a := range p.Actions {
+		fmt.Fprintf(&b, "- %s\n", a.Description)
+	}
+
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "! %s\n", w)
+	}
+
+	return b.String()
+}
+
+// Apply performs the actions of the plan in order.
+//
+// It stops at the first failing action.
+func (p *Plan) Apply(ctx context.Context, c *feitian.Card) error {
+	for _, a := range p.Actions {
+		if err := a.apply(ctx, c); err != nil {
+			return fmt.Errorf("failed to %s: %w", a.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// NewPlan compares the desired state cfg to the current state of the key
+// and returns the operations required to reconcile them.
+//
+// The applet does not allow to read back secrets, the number of digits or
+// counters. Credentials are therefore considered equal if their name, kind,
+// algorithm and touch policy match. To replace the secret of an existing
+// credential, it must be renamed or deleted first.
+//
+// The key does not report the touch policy. A changed policy can therefore
+// not be detected. Credentials which require a touch are only permitted if
+// c.ExperimentalTouch is set. For those, the plan contains a warning that
+// their policy has not been checked.
+func NewPlan(ctx context.Context, c *feitian.Card, cfg *Config) (*Plan, error) {
+	desired := map[feitian.Slot]*feitian.Credential{}
+
+	configs := map[feitian.Slot]*SlotConfig{
+		feitian.Slot1: cfg.Slot1,
+		feitian.Slot2: cfg.Slot2,
+	}
+
+	for _, slot := range []feitian.Slot{feitian.Slot1, feitian.Slot2} {
+		sc := configs[slot]
+		if sc == nil {
+			continue
+		}
+
+		cred, err := sc.Credential()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, slot, err)
+		} else if cred.Touch == feitian.TouchRequired && !c.ExperimentalTouch {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, slot, feitian.ErrExperimentalTouch)
+		}
+
+		desired[slot] = &cred
+	}
+
+	items, err := c.ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	current := map[feitian.Slot]feitian.ListItem{}
+	currentDefault := ""
+
+	for _, item := range items {
+		if item.IsDefault() {
+			currentDefault = item.Name
+		} else {
+			current[item.Slot] = item
+		}
+	}
+
+	p := &Plan{}
+	replaced := map[feitian.Slot]bool{}
+	names := map[feitian.Slot]string{}
+
+	for _, slot := range []feitian.Slot{feitian.Slot1, feitian.Slot2} {
+		cur, hasCur := current[slot]
+		want, hasWant := desired[slot]
+
+		if hasCur {
+			names[slot] = cur.Name
+		}
+
+		switch {
+		case hasWant && hasCur && matches(cur, want):
+			if cur.Touch == feitian.TouchUnknown && (want.Touch == feitian.TouchRequired || c.ExperimentalTouch) {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("touch policy of credential %q in %s can not be checked", cur.Name, slot))
+			}
+
+			continue
+
+		case !hasWant && (!hasCur || !cfg.DeleteUnconfigured):
+			continue
+		}
+
+		if hasCur {
+			p.Actions = append(p.Actions, deleteAction(slot, cur.Name))
+			delete(names, slot)
+		}
+
+		if hasWant {
+			p.Actions = append(p.Actions, putAction(slot, *want))
+			names[slot] = want.Name
+			replaced[slot] = true
+		}
+	}
+
+	if cfg.Language != "" {
+		lang, err := feitian.ParseLanguage(cfg.Language)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+
+		cur, err := c.LanguageContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get language: %w", err)
+		}
+
+		if cur != lang {
+			p.Actions = append(p.Actions, languageAction(lang))
+		}
+	}
+
+	if cfg.Default != "" {
+		slot, err := feitian.ParseSlot(cfg.Default)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		} else if slot == feitian.SlotDefault {
+			return nil, fmt.Errorf("%w: default: %w", ErrInvalidConfig, feitian.ErrInvalidSlot)
+		}
+
+		name, ok := names[slot]
+		if !ok {
+			return nil, fmt.Errorf("%w: default: %w", ErrInvalidConfig, feitian.ErrSlotNotConfigured)
+		}
+
+		// Reprogramming a slot might reset its default flag
+		if name != currentDefault || replaced[slot] {
+			p.Actions = append(p.Actions, defaultAction(slot, name))
+		}
+	}
+
+	if cfg.Enabled != nil {
+		state := feitian.OFF
+		if *cfg.Enabled {
+			state = feitian.ON
+		}
+
+		cur, err := c.ApplicationStateContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get application state: %w", err)
+		}
+
+		if cur != state {
+			p.Actions = append(p.Actions, stateAction(state))
+		}
+	}
+
+	return p, nil
+}
+
+// matches returns true if the listed item matches cred.
+// An unknown touch policy matches any policy. See NewPlan.
+func matches(item feitian.ListItem, cred *feitian.Credential) bool {
+	if item.Name != cred.Name || item.Kind != cred.Kind || item.Algorithm != cred.Algorithm {
+		return false
+	}
+
+	return item.Touch == feitian.TouchUnknown || item.Touch == cred.Touch
+}
+
+func deleteAction(slot feitian.Slot, name string) Action {
+	return Action{
+		Description: fmt.Sprintf("delete credential %q from %s", name, slot),
+		apply: func(ctx context.Context, c *feitian.Card) error {
+			return c.DeleteContext(ctx, slot, name)
+		},
+	}
+}
+
+func putAction(slot feitian.Slot, cred feitian.Credential) Action {
+	return Action{
+		Description: fmt.Sprintf("put %s credential %q into %s", cred.Kind, cred.Name, slot),
+		apply: func(ctx context.Context, c *feitian.Card) error {
+			return c.PutCredentialContext(ctx, slot, cred)
+		},
+	}
+}
+
+func languageAction(lang feitian.Language) Action {
+	return Action{
+		Description: fmt.Sprintf("set language to %s", lang),
+		apply: func(ctx context.Context, c *feitian.Card) error {
+			return c.SetLanguageContext(ctx, lang)
+		},
+	}
+}
+
+func defaultAction(slot feitian.Slot, name string) Action {
+	return Action{
+		Description: fmt.Sprintf("set default credential to %q of %s", name, slot),
+		apply: func(ctx context.Context, c *feitian.Card) error {
+			return c.SetDefaultContext(ctx, slot, name)
+		},
+	}
+}
+
+func stateAction(state feitian.AppState) Action {
+	desc := "disable OTP application"
+	if state == feitian.ON {
+		desc = "enable OTP application"
+	}
+
+	return Action{
+		Description: desc,
+		apply: func(ctx context.Context, c *feitian.Card) error {
+			return c.SetApplicationStateContext(ctx, state)
+		},
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package provision_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/emulator"
+	"cunicu.li/go-feitian-oath/provision"
+)
+
+const testConfig = `
+slot1:
+  name: ACME:vpn
+  kind: totp
+  secret: GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ
+  touch: true
+slot2:
+  name: legacy
+  kind: static
+  password: s3cret-Passw0rd
+language: fr
+default: slot2
+enabled: true
+`
+
+func withCard(t *testing.T, cb func(require *require.Assertions, c *feitian.Card)) {
+	require := require.New(t)
+
+	c, err := feitian.NewCard(emulator.New())
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	cb(require, c)
+
+	err = c.Close()
+	require.NoError(err)
+}
+
+func TestProvision(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		ctx := context.Background()
+
+		cfg, err := provision.Load(strings.NewReader(testConfig))
+		require.NoError(err)
+
+		// The touch policy is experimental
+		_, err = provision.NewPlan(ctx, c, cfg)
+		require.ErrorIs(err, provision.ErrInvalidConfig)
+		require.ErrorIs(err, feitian.ErrExperimentalTouch)
+
+		c.ExperimentalTouch = true
+
+		p, err := provision.NewPlan(ctx, c, cfg)
+		require.NoError(err)
+		require.Empty(p.Warnings)
+		require.Equal(`- put totp credential "ACME:vpn" into slot1
+- put static credential "legacy" into slot2
+- set language to fr
+- set default credential to "legacy" of slot2
+`, p.String())
+
+		err = p.Apply(ctx, c)
+		require.NoError(err)
+
+		items, err := c.List()
+		require.NoError(err)
+		require.Equal([]feitian.ListItem{
+			{Name: "ACME:vpn", Slot: feitian.Slot1, Algorithm: feitian.SHA1, Kind: feitian.TOTP},
+			{Name: "legacy", Slot: feitian.Slot2, Algorithm: feitian.SHA1, Kind: feitian.StaticPassword},
+			{Name: "legacy", Slot: feitian.SlotDefault, Algorithm: feitian.SHA1, Kind: feitian.StaticPassword},
+		}, items)
+
+		lang, err := c.Language()
+		require.NoError(err)
+		require.Equal(feitian.LangFrench, lang)
+
+		// A second run must not change anything
+		// but the touch policy can not be checked
+		p, err = provision.NewPlan(ctx, c, cfg)
+		require.NoError(err)
+		require.True(p.Empty())
+		require.Equal(`No changes required
+! touch policy of credential "ACME:vpn" in slot1 can not be checked
+! touch policy of credential "legacy" in slot2 can not be checked
+`, p.String())
+
+		c.ExperimentalTouch = false
+		cfg.Slot1.Touch = false
+
+		p, err = provision.NewPlan(ctx, c, cfg)
+		require.NoError(err)
+		require.True(p.Empty())
+		require.Equal("No changes required\n", p.String())
+	})
+}
+
+func TestProvisionChanges(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		ctx := context.Background()
+
+		err := c.Put(feitian.Slot1, "outdated", []byte("12345678901234567890"), feitian.SHA1, feitian.HOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "keep", []byte("12345678901234567890"), feitian.SHA1, feitian.TOTP, 6, 0)
+		require.NoError(err)
+
+		enabled := false
+		cfg := &provision.Config{
+			Slot1: &provision.SlotConfig{
+				URI: "otpauth://totp/ACME:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA256",
+			},
+			Enabled: &enabled,
+		}
+
+		p, err := provision.NewPlan(ctx, c, cfg)
+		require.NoError(err)
+		require.Equal(`- delete credential "outdated" from slot1
+- put totp credential "ACME:alice" into slot1
+- disable OTP application
+`, p.String())
+
+		err = p.Apply(ctx, c)
+		require.NoError(err)
+
+		state, err := c.ApplicationState()
+		require.NoError(err)
+		require.Equal(feitian.OFF, state)
+
+		// Unconfigured slots are only cleared on request
+		cfg.DeleteUnconfigured = true
+
+		p, err = provision.NewPlan(ctx, c, cfg)
+		require.NoError(err)
+		require.Equal(`- delete credential "keep" from slot2
+`, p.String())
+
+		err = p.Apply(ctx, c)
+		require.NoError(err)
+
+		items, err := c.List()
+		require.NoError(err)
+		require.Len(items, 1)
+		require.Equal("ACME:alice", items[0].Name)
+		require.Equal(feitian.SHA256, items[0].Algorithm)
+	})
+}
+
+func TestProvisionInvalid(t *testing.T) {
+	withCard(t, func(require *require.Assertions, c *feitian.Card) {
+		ctx := context.Background()
+
+		_, err := provision.Load(strings.NewReader("slot3: {}"))
+		require.ErrorIs(err, provision.ErrInvalidConfig)
+
+		cfg, err := provision.Load(strings.NewReader(`{"slot1": {"name": "x", "kind": "totp", "secret": "!!"}}`))
+		require.NoError(err)
+
+		_, err = provision.NewPlan(ctx, c, cfg)
+		require.ErrorIs(err, provision.ErrInvalidConfig)
+		require.ErrorIs(err, feitian.ErrInvalidSecret)
+
+		_, err = provision.NewPlan(ctx, c, &provision.Config{Default: "slot1"})
+		require.ErrorIs(err, feitian.ErrSlotNotConfigured)
+
+		_, err = provision.NewPlan(ctx, c, &provision.Config{Language: "de"})
+		require.ErrorIs(err, feitian.ErrUnsupportedLanguage)
+	})
+}
@@ -3,41 +3,69 @@
 
 package feitian
 
-import (
-	"encoding/binary"
+import "context"
 
-	iso "cunicu.li/go-iso7816"
-	"cunicu.li/go-iso7816/encoding/tlv"
-)
+type putOptions struct {
+	touch TouchPolicy
+}
+
+// PutOption configures optional properties of a credential programmed by Put.
+type PutOption func(o *putOptions)
+
+// WithTouch sets the touch policy of the credential.
+// Credentials are programmed with TouchNone by default.
+// TouchRequired is experimental. See Card.ExperimentalTouch.
+func WithTouch(policy TouchPolicy) PutOption {
+	return func(o *putOptions) {
+		o.touch = policy
+	}
+}
 
 // Put programs a OTP credential.
-func (c *Card) Put(slot Slot, name string, secret []byte, alg Algorithm, kind Kind, digits int, counter uint32) error {
+//
+// Unlike PutCredential, the counter is only passed to the applet which
+// ignores it. HOTP credentials therefore always start at counter zero.
+// Put also keeps its original checks of the name, slot and digits
+// instead of the validation of Credential.Validate.
+func (c *Card) Put(slot Slot, name string, secret []byte, alg Algorithm, kind Kind, digits int, counter uint32, opts ...PutOption) error {
+	return c.PutContext(context.Background(), slot, name, secret, alg, kind, digits, counter, opts...)
+}
+
+// PutContext is like Put but honors the cancellation of ctx.
+func (c *Card) PutContext(ctx context.Context, slot Slot, name string, secret []byte, alg Algorithm, kind Kind, digits int, counter uint32, opts ...PutOption) error {
+	o := putOptions{
+		touch: TouchNone,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	k := Credential{
+		Name:      name,
+		Kind:      kind,
+		Algorithm: alg,
+		Secret:    secret,
+		Digits:    digits,
+		Counter:   counter,
+		Touch:     o.touch,
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	// All checks precede the first command
 	if err := checkName(name); err != nil {
 		return err
 	} else if err := checkSlot(slot); err != nil {
 		return err
 	} else if err := checkDigits(digits); err != nil {
 		return err
-	}
-
-	data, err := tlv.EncodeSimple(
-		tlv.New(tagKey, byte(alg), byte(kind), byte(digits), secret),
-		tlv.New(tagName, name),
-		tlv.New(tagTouch, byte(tagTouch)),
-		tlv.New(tagIMF, binary.BigEndian.AppendUint32(nil, counter)))
-	if err != nil {
+	} else if _, err := o.touch.encode(); err != nil {
+		return err
+	} else if err := c.checkTouch(o.touch); err != nil {
 		return err
 	}
 
-	// Really weird quirk?
-	data[1]--
-
-	_, err = c.Send(&iso.CAPDU{
-		Ins:  insPut,
-		P1:   0x00,
-		P2:   byte(slot),
-		Data: data,
-	})
-
-	return err
+	return c.put(ctx, slot, k)
 }
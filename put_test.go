@@ -4,6 +4,7 @@
 package feitian_test
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -15,7 +16,9 @@ func TestPut(t *testing.T) {
 	withCard(t, false, func(t *testing.T, c *feitian.Card) {
 		require := require.New(t)
 
-		for _, vs := range vectors {
+		// The vectors must be put in the order of the recorded transcript.
+		// Ranging over the vectors map would randomize it.
+		for _, vs := range [][]vector{vectorsTOTP, vectorsHOTP, vectorsChalResp} {
 			for _, v := range vs {
 				err := c.Put(feitian.Slot1, v.Name, v.Secret, v.Algorithm, v.Kind, v.Digits, v.Counter)
 				require.NoError(err)
@@ -32,3 +35,34 @@ func TestPut(t *testing.T) {
 		}
 	})
 }
+
+func TestPutInvalid(t *testing.T) {
+	withEmulator(t, func(t *testing.T, c *feitian.Card) {
+		require := require.New(t)
+
+		// No command must be sent for invalid arguments
+		c.Tracer = func(tr feitian.Trace) {
+			require.Failf("unexpected command", "%s sent for invalid arguments", tr.Name())
+		}
+
+		err := c.Put(feitian.Slot1, "abc", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.ErrorIs(err, feitian.ErrNameTooShort)
+
+		err = c.Put(feitian.Slot(0x05), "test", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+		require.ErrorIs(err, feitian.ErrInvalidSlot)
+
+		err = c.Put(feitian.Slot1, "test", testSecretSHA1, feitian.SHA1, feitian.TOTP, 7, 0)
+		require.ErrorIs(err, feitian.ErrInvalidDigits)
+
+		err = c.Put(feitian.Slot1, "test", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0, feitian.WithTouch(feitian.TouchRequired))
+		require.ErrorIs(err, feitian.ErrExperimentalTouch)
+
+		err = c.PutCredential(feitian.Slot1, feitian.Credential{
+			Name:      "test",
+			Kind:      feitian.TOTP,
+			Algorithm: feitian.SHA1,
+			Secret:    bytes.Repeat([]byte{1}, 65),
+		})
+		require.ErrorIs(err, feitian.ErrInvalidSecret)
+	})
+}
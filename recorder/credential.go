@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder
+
+import (
+	"errors"
+	"slices"
+
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+const truncatedLength = 4 // Length of truncated responses without the number of digits
+
+var errMalformedPut = errors.New("malformed put command")
+
+// slotName identifies a credential programmed during the recording.
+type slotName struct {
+	slot byte
+	name string
+}
+
+// credential is a credential programmed during the recording
+// with the test secret of the recorded put command.
+type credential struct {
+	kind      feitian.Kind
+	algorithm feitian.Algorithm
+	secret    []byte
+	counter   uint64
+}
+
+// parsePut decodes the data of a put command.
+func parsePut(data []byte) (string, *credential, error) {
+	if len(data) < 2 || tlv.Tag(data[0]) != applet.TagKey {
+		return "", nil, errMalformedPut
+	}
+
+	// The length of the key is off by one, see feitian.Card.PutCredential().
+	data = slices.Clone(data)
+	data[1]++
+
+	tvs, err := tlv.DecodeSimple(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key, _, _ := tvs.Get(applet.TagKey)
+	name, _, _ := tvs.Get(applet.TagName)
+
+	if len(key) < applet.KeyHeaderLength {
+		return "", nil, errMalformedPut
+	}
+
+	// The applet starts HOTP counters at zero
+	return string(name), &credential{
+		algorithm: feitian.Algorithm(key[0]),
+		kind:      feitian.Kind(key[1]),
+		secret:    slices.Clone(key[applet.KeyHeaderLength:]),
+	}, nil
+}
+
+// calculate returns the response of the applet without the number of digits.
+func (c *credential) calculate(challenge []byte, truncate bool) ([]byte, error) {
+	return applet.Calculate(byte(c.kind), c.algorithm, c.secret, &c.counter, challenge, truncate)
+}
@@ -0,0 +1,649 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+// Package recorder records the commands exchanged with a key into
+// transcripts which can be replayed by the tests of this module.
+//
+// The transcripts use the mockfile format of cunicu.li/go-iso7816/test.
+// They are stored at DIR/TEST/DEVICE, e.g. mockdata/TestPut/a9-3301,
+// where DEVICE identifies the model and firmware version of the key.
+//
+// A transcript contains all secrets in clear text unless the Recorder
+// is created with WithTestSecrets().
+package recorder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+const headerLength = 5 // CLA, INS, P1, P2 and Lc
+
+//nolint:gochecknoglobals
+var (
+	// anonymousID replaces the device IDs reported by the applet.
+	anonymousID = []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}
+
+	// testSecret is repeated to replace secrets like the test vectors of RFC 4226.
+	testSecret = []byte("1234567890")
+)
+
+var _ iso.PCSCCard = (*Recorder)(nil)
+
+type call struct {
+	Start, End time.Time
+	Method     string
+	Command    []byte
+	Response   []byte
+}
+
+// Option configures a Recorder.
+type Option func(r *Recorder)
+
+// WithAnonymousID replaces the device ID reported by the applet with a fixed value.
+//
+// The access code key is derived from the device ID. Hence, transcripts
+// which include SetCode() or Validate() can not be replayed after anonymisation.
+func WithAnonymousID() Option {
+	return func(r *Recorder) {
+		r.anonymousID = true
+	}
+}
+
+// WithTestSecrets replaces secrets with test values of the same length.
+//
+// These are the keys and static passwords of Put commands as well as the
+// access code keys of SetCode commands. The responses of Calculate and
+// CalculateAll and the challenge-responses of SetCode and Validate are
+// recalculated with these test secrets. The counters of HOTP credentials
+// are followed from the Put command on.
+//
+// Credentials and access codes which have been programmed before the
+// recording started are unknown. Their responses are replaced by test
+// values which do not match any secret.
+func WithTestSecrets() Option {
+	return func(r *Recorder) {
+		r.testSecrets = true
+	}
+}
+
+// Recorder is an iso7816.PCSCCard which records all commands
+// exchanged with the wrapped card.
+type Recorder struct {
+	iso.PCSCCard
+
+	path        string
+	anonymousID bool
+	testSecrets bool
+
+	mu      sync.Mutex
+	calls   []call
+	pending []call // Parts of a chained response which has not been completed yet
+
+	// State of the applet which is followed to recalculate
+	// responses with the test secrets.
+	credentials   map[slotName]*credential
+	codeKey       []byte
+	codeAlgorithm feitian.Algorithm
+	challenge     []byte
+}
+
+// New wraps card into a Recorder which writes its transcript
+// to dir/test/device when it is closed.
+func New(card iso.PCSCCard, dir, test, device string, opts ...Option) *Recorder {
+	r := &Recorder{
+		PCSCCard:      card,
+		path:          filepath.Join(dir, test, device),
+		credentials:   map[slotName]*credential{},
+		codeAlgorithm: feitian.SHA1,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Path returns the path of the transcript.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Transmit implements iso7816.PCSCCard.
+func (r *Recorder) Transmit(cmd []byte) ([]byte, error) {
+	start := time.Now()
+	resp, err := r.PCSCCard.Transmit(cmd)
+	end := time.Now()
+
+	if err == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		// A new command terminates an incomplete chain
+		if !isSendRemaining(cmd) {
+			r.flush()
+		}
+
+		r.pending = append(r.pending, call{
+			Start:    start,
+			End:      end,
+			Method:   "Transmit",
+			Command:  r.rewriteCommand(cmd),
+			Response: slices.Clone(resp),
+		})
+
+		if len(resp) < 2 || !iso.Code(resp[len(resp)-2:]).HasMore() {
+			r.flush()
+		}
+	}
+
+	return resp, err
+}
+
+// flush records the pending calls of a response which the applet
+// returned in multiple parts. The parts are reassembled before
+// they are rewritten as a whole and split again afterwards.
+// The caller must hold r.mu.
+func (r *Recorder) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+
+	var resp []byte
+
+	for _, c := range r.pending {
+		data, _ := splitStatus(c.Response)
+		resp = append(resp, data...)
+	}
+
+	// The status of the last part applies to the reassembled response
+	_, status := splitStatus(r.pending[len(r.pending)-1].Response)
+	resp = r.rewriteResponse(r.pending[0].Command, append(resp, status...))
+
+	for _, c := range r.pending {
+		data, _ := splitStatus(c.Response)
+		resp = resp[copy(data, resp):]
+
+		r.calls = append(r.calls, c)
+	}
+
+	r.pending = nil
+}
+
+// isSendRemaining checks if cmd retrieves the next part of a chained response.
+func isSendRemaining(cmd []byte) bool {
+	if len(cmd) < 2 {
+		return false
+	}
+
+	ins := iso.Instruction(cmd[1])
+
+	return ins == applet.InsSendRemaining || ins == applet.InsSendRemainingFT
+}
+
+// splitStatus splits a response into its data and status words.
+func splitStatus(resp []byte) ([]byte, []byte) {
+	if len(resp) < 2 {
+		return nil, resp
+	}
+
+	return resp[:len(resp)-2], resp[len(resp)-2:]
+}
+
+// BeginTransaction implements iso7816.PCSCCard.
+func (r *Recorder) BeginTransaction() error {
+	start := time.Now()
+	err := r.PCSCCard.BeginTransaction()
+
+	r.record(call{
+		Start:  start,
+		End:    time.Now(),
+		Method: "BeginTransaction",
+	})
+
+	return err
+}
+
+// EndTransaction implements iso7816.PCSCCard.
+func (r *Recorder) EndTransaction() error {
+	start := time.Now()
+	err := r.PCSCCard.EndTransaction()
+
+	r.record(call{
+		Start:  start,
+		End:    time.Now(),
+		Method: "EndTransaction",
+	})
+
+	return err
+}
+
+// Close writes the transcript and closes the wrapped card.
+func (r *Recorder) Close() error {
+	if err := r.WriteFile(); err != nil {
+		return err
+	}
+
+	return r.PCSCCard.Close()
+}
+
+// WriteFile writes the transcript to Path().
+func (r *Recorder) WriteFile() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer f.Close()
+
+	if err := r.Write(f); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Write writes the transcript to w.
+func (r *Recorder) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.flush()
+
+	var b bytes.Buffer
+
+	fmt.Fprintln(&b, "mockfile")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "file.version", "v2")
+	fmt.Fprintln(&b, "file.created", time.Now().Format(time.RFC3339))
+	fmt.Fprintln(&b, "file.creator", creator())
+	fmt.Fprintln(&b)
+
+	if mc, ok := r.Base().(iso.MetadataCard); ok {
+		meta := mc.Metadata()
+		keys := []string{}
+
+		for key := range meta {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			fmt.Fprintln(&b, "meta", key, meta[key])
+		}
+	}
+
+	if len(r.calls) > 0 {
+		fmt.Fprintf(&b, "\n#  %8s %8s method\n", "start", "end")
+
+		first := r.calls[0].Start
+
+		for _, c := range r.calls {
+			start := inMilliseconds(c.Start.Sub(first))
+			end := inMilliseconds(c.End.Sub(first))
+
+			switch c.Method {
+			case "Transmit":
+				fmt.Fprintf(&b, "on %8.3f %8.3f %s %s %s\n", start, end, c.Method,
+					hex.EncodeToString(c.Command), hex.EncodeToString(c.Response))
+			default:
+				fmt.Fprintf(&b, "on %8.3f %8.3f %s\n", start, end, c.Method)
+			}
+		}
+	}
+
+	_, err := b.WriteTo(w)
+
+	return err
+}
+
+func (r *Recorder) record(c call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.flush()
+
+	r.calls = append(r.calls, c)
+}
+
+// rewriteCommand returns the command as it is recorded.
+// The caller must hold r.mu.
+func (r *Recorder) rewriteCommand(cmd []byte) []byte {
+	cmd = slices.Clone(cmd)
+
+	if !r.testSecrets || len(cmd) < headerLength {
+		return cmd
+	}
+
+	data := cmd[headerLength:]
+
+	switch iso.Instruction(cmd[1]) {
+	case applet.InsPut:
+		// The length of the key is off by one, see feitian.Card.PutCredential().
+		if len(data) >= 2 && tlv.Tag(data[0]) == applet.TagKey {
+			fill(data, 2+applet.KeyHeaderLength, 2+int(data[1])+1)
+		}
+
+	case applet.InsSetCode:
+		tvs, err := tlv.DecodeSimple(data)
+		if err != nil {
+			fill(data, 0, len(data))
+			break
+		}
+
+		key, _, _ := tvs.Get(applet.TagKey)
+		if len(key) == 0 {
+			break // The access code is cleared
+		}
+
+		challenge, _, _ := tvs.Get(applet.TagChallenge)
+		response, _, _ := tvs.Get(applet.TagResponse)
+
+		// The value of the key starts with its type
+		fill(key, 1, len(key))
+		replaceHMAC(response, feitian.Algorithm(key[0]&0x0F), key[1:], challenge)
+
+	case applet.InsValidate:
+		tvs, err := tlv.DecodeSimple(data)
+		if err != nil {
+			fill(data, 0, len(data))
+			break
+		}
+
+		// The response to the challenge of the select command
+		response, _, _ := tvs.Get(applet.TagResponse)
+		replaceHMAC(response, r.codeAlgorithm, r.codeKey, r.challenge)
+	}
+
+	return cmd
+}
+
+// rewriteResponse returns the response to the recorded command cmd as it is recorded.
+// The caller must hold r.mu.
+func (r *Recorder) rewriteResponse(cmd, resp []byte) []byte {
+	resp = slices.Clone(resp)
+
+	if len(cmd) < 4 || len(resp) < 2 {
+		return resp
+	}
+
+	ins, p2 := iso.Instruction(cmd[1]), cmd[3]
+	data := resp[:len(resp)-2]
+
+	var cmdData []byte
+	if len(cmd) > headerLength {
+		cmdData = cmd[headerLength:]
+	}
+
+	if ins == iso.InsSelect && r.anonymousID {
+		if vs, err := applet.Values(data, applet.TagName); err == nil {
+			for _, v := range vs {
+				copy(v, anonymousID)
+			}
+		}
+	}
+
+	if !r.testSecrets {
+		return resp
+	}
+
+	switch ins {
+	case iso.InsSelect:
+		r.selected(data)
+
+	case applet.InsCalculate:
+		r.calculate(cmdData, data, p2)
+
+	case applet.InsCalculateAll:
+		r.calculateAll(cmdData, data)
+
+	case applet.InsValidate:
+		r.validate(cmdData, data)
+	}
+
+	if iso.Code(resp[len(resp)-2:]) == iso.ErrSuccess {
+		r.track(ins, p2, cmdData)
+	}
+
+	return resp
+}
+
+// selected retains the challenge and algorithm of a protected applet
+// from the response to the select command.
+func (r *Recorder) selected(data []byte) {
+	r.challenge = nil
+
+	tvs, err := tlv.DecodeSimple(data)
+	if err != nil {
+		return
+	}
+
+	for _, tv := range tvs {
+		switch tv.Tag {
+		case applet.TagChallenge:
+			r.challenge = slices.Clone(tv.Value)
+
+		case applet.TagAlgorithm:
+			if len(tv.Value) > 0 {
+				r.codeAlgorithm = feitian.Algorithm(tv.Value[0])
+			}
+		}
+	}
+}
+
+// track follows the credentials and the access code key
+// after a successful command.
+func (r *Recorder) track(ins iso.Instruction, p2 byte, data []byte) {
+	switch ins {
+	case applet.InsPut:
+		if name, cred, err := parsePut(data); err == nil {
+			r.credentials[slotName{p2, name}] = cred
+		}
+
+	case applet.InsDelete:
+		if tvs, err := tlv.DecodeSimple(data); err == nil {
+			name, _, _ := tvs.Get(applet.TagName)
+			delete(r.credentials, slotName{p2, string(name)})
+		}
+
+	case applet.InsSwapSlot:
+		swapped := map[slotName]*credential{}
+
+		for k, cred := range r.credentials {
+			switch feitian.Slot(k.slot) {
+			case feitian.Slot1:
+				k.slot = byte(feitian.Slot2)
+			case feitian.Slot2:
+				k.slot = byte(feitian.Slot1)
+			}
+
+			swapped[k] = cred
+		}
+
+		r.credentials = swapped
+
+	case applet.InsReset:
+		clear(r.credentials)
+		r.codeKey = nil
+
+	case applet.InsSetCode:
+		r.codeKey = nil
+
+		if tvs, err := tlv.DecodeSimple(data); err == nil {
+			if key, _, _ := tvs.Get(applet.TagKey); len(key) > 0 {
+				r.codeKey = slices.Clone(key[1:])
+			}
+		}
+	}
+}
+
+// calculate recalculates the response to a calculate command
+// with the test secret of the credential.
+func (r *Recorder) calculate(cmdData, data []byte, slot byte) {
+	tvs, err := tlv.DecodeSimple(cmdData)
+	if err != nil {
+		fill(data, 0, len(data))
+		return
+	}
+
+	name, _, _ := tvs.Get(applet.TagName)
+	challenge, _, _ := tvs.Get(applet.TagChallenge)
+
+	vs, err := applet.Values(data, applet.TagResponse, applet.TagTResponse)
+	if err != nil {
+		fill(data, 0, len(data))
+		return
+	}
+
+	for _, v := range vs {
+		r.replaceResponse(v, r.lookup(slot, string(name)), challenge)
+	}
+}
+
+// calculateAll recalculates the responses to a calculate all command
+// with the test secrets of the credentials.
+func (r *Recorder) calculateAll(cmdData, data []byte) {
+	tvs, err := tlv.DecodeSimple(cmdData)
+	if err != nil {
+		fill(data, 0, len(data))
+		return
+	}
+
+	challenge, _, _ := tvs.Get(applet.TagChallenge)
+
+	resps, err := tlv.DecodeSimple(data)
+	if err != nil {
+		fill(data, 0, len(data))
+		return
+	}
+
+	// Each response follows the name of its credential
+	var name []byte
+
+	for _, tv := range resps {
+		switch tv.Tag {
+		case applet.TagName:
+			name = tv.Value
+
+		case applet.TagResponse, applet.TagTResponse:
+			r.replaceResponse(tv.Value, r.lookup(byte(feitian.SlotDefault), string(name)), challenge)
+		}
+	}
+}
+
+// validate recalculates the response of the applet to the
+// challenge of a validate command with the test access code key.
+func (r *Recorder) validate(cmdData, data []byte) {
+	tvs, err := tlv.DecodeSimple(cmdData)
+	if err != nil {
+		fill(data, 0, len(data))
+		return
+	}
+
+	challenge, _, _ := tvs.Get(applet.TagChallenge)
+
+	vs, err := applet.Values(data, applet.TagResponse)
+	if err != nil {
+		fill(data, 0, len(data))
+		return
+	}
+
+	for _, v := range vs {
+		replaceHMAC(v, r.codeAlgorithm, r.codeKey, challenge)
+	}
+}
+
+// lookup returns the credential with the given name in a slot.
+// Both slots are searched for the default slot.
+func (r *Recorder) lookup(slot byte, name string) *credential {
+	if feitian.Slot(slot) != feitian.SlotDefault {
+		return r.credentials[slotName{slot, name}]
+	}
+
+	for _, s := range []feitian.Slot{feitian.Slot1, feitian.Slot2} {
+		if cred, ok := r.credentials[slotName{byte(s), name}]; ok {
+			return cred
+		}
+	}
+
+	return nil
+}
+
+// replaceResponse replaces the value of a response TLV by the one
+// calculated with the test secret of cred. The value starts with
+// the number of digits. Credentials which have not been programmed
+// during the recording are unknown. Their responses are replaced by
+// test values which do not match any secret.
+func (r *Recorder) replaceResponse(v []byte, cred *credential, challenge []byte) {
+	if len(v) < 1 {
+		return
+	} else if cred == nil {
+		fill(v, 1, len(v))
+		return
+	}
+
+	resp, err := cred.calculate(challenge, len(v)-1 == truncatedLength)
+	if err != nil || len(resp) != len(v)-1 {
+		fill(v, 1, len(v))
+		return
+	}
+
+	copy(v[1:], resp)
+}
+
+// replaceHMAC replaces b by the HMAC of data with the given key.
+// It is replaced by test values if the key is unknown.
+func replaceHMAC(b []byte, alg feitian.Algorithm, key, data []byte) {
+	if mac, err := applet.HMAC(alg, key, data); err == nil && key != nil && len(mac) == len(b) {
+		copy(b, mac)
+		return
+	}
+
+	fill(b, 0, len(b))
+}
+
+// fill replaces b[from:to] with the repeated test secret.
+func fill(b []byte, from, to int) {
+	to = min(to, len(b))
+	for i := from; i < to; i++ {
+		b[i] = testSecret[(i-from)%len(testSecret)]
+	}
+}
+
+// creator identifies the user and host which recorded a transcript.
+func creator() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return name + "@" + host
+}
+
+func inMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,347 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package recorder_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+	"cunicu.li/go-iso7816/test"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/emulator"
+	"cunicu.li/go-feitian-oath/recorder"
+)
+
+//nolint:gochecknoglobals
+var (
+	testSecret   = []byte("my-secret-seed-value")
+	testPassword = []byte("my static password")
+
+	// The test values which replace the secrets above
+	replacedSecret   = []byte("12345678901234567890")
+	replacedPassword = []byte("123456789012345678")
+)
+
+// session exchanges some commands with the card and returns the calculated codes.
+func session(t *testing.T, pcscCard iso.PCSCCard) (feitian.Code, feitian.Code) {
+	return sessionWithSecrets(t, pcscCard, testSecret, testPassword)
+}
+
+func sessionWithSecrets(t *testing.T, pcscCard iso.PCSCCard, secret, password []byte) (feitian.Code, feitian.Code) {
+	require := require.New(t)
+
+	c, err := feitian.NewCard(pcscCard)
+	require.NoError(err)
+
+	c.Clock = func() time.Time { return time.Unix(1111111109, 0) }
+
+	err = c.Select()
+	require.NoError(err)
+
+	err = c.Put(feitian.Slot1, "totp", secret, feitian.SHA1, feitian.TOTP, 8, 0)
+	require.NoError(err)
+
+	err = c.Put(feitian.Slot2, "static", password, feitian.SHA1, feitian.StaticPassword, 6, 0)
+	require.NoError(err)
+
+	code, err := c.Calculate(feitian.Slot1, "totp")
+	require.NoError(err)
+
+	pass, err := c.CalculateWithChallenge(feitian.Slot2, "static", nil, false)
+	require.NoError(err)
+
+	err = c.Close()
+	require.NoError(err)
+
+	return code, pass
+}
+
+func TestRecordReplay(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	e := emulator.New()
+	r := recorder.New(e, "mockdata", t.Name(), "emulator", recorder.WithAnonymousID())
+	require.Equal(t, filepath.Join("mockdata", "TestRecordReplay", "emulator"), r.Path())
+
+	code, pass := session(t, r)
+
+	err := r.Close()
+	require.NoError(t, err)
+
+	transcript, err := os.ReadFile(r.Path())
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(transcript, []byte("mockfile\n\nfile.version v2\n")))
+	require.Contains(t, string(transcript), "file.creator ")
+	require.Contains(t, string(transcript), "on    0.000")
+	require.Contains(t, string(transcript), "BeginTransaction")
+	require.Contains(t, string(transcript), "0011223344556677")
+
+	// Replay the transcript via the mocked card used by the tests
+	t.Run("emulator", func(t *testing.T) {
+		m, err := test.NewMockCard(t, nil)
+		require.NoError(t, err)
+
+		replayedCode, replayedPass := session(t, m)
+		require.Equal(t, code.OTP(), replayedCode.OTP())
+		require.Equal(t, pass.Digest, replayedPass.Digest)
+
+		err = m.Close()
+		require.NoError(t, err)
+	})
+}
+
+func TestRecordTestSecrets(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	r := recorder.New(emulator.New(), "mockdata", t.Name(), "emulator", recorder.WithTestSecrets())
+
+	code, pass := session(t, r)
+
+	var b bytes.Buffer
+
+	err := r.Write(&b)
+	require.NoError(t, err)
+
+	transcript := b.String()
+	for _, secret := range [][]byte{testSecret, testPassword, code.Digest, pass.Digest} {
+		require.NotContains(t, transcript, hex.EncodeToString(secret))
+	}
+
+	// Secrets are replaced by test values of the same length
+	require.Contains(t, transcript, hex.EncodeToString(replacedSecret)+"51"+hex.EncodeToString([]byte("\x04totp")))
+
+	err = r.Close()
+	require.NoError(t, err)
+
+	// The responses have been calculated with the test values
+	t.Run("emulator", func(t *testing.T) {
+		m, err := test.NewMockCard(t, nil)
+		require.NoError(t, err)
+
+		replayedCode, replayedPass := sessionWithSecrets(t, m, replacedSecret, replacedPassword)
+		require.Equal(t, "07081804", replayedCode.OTP()) // RFC 6238 Appendix B
+		require.Equal(t, replacedPassword, replayedPass.Digest)
+
+		err = m.Close()
+		require.NoError(t, err)
+	})
+}
+
+func TestRecordTestSecretsHOTP(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	hotp := func(t *testing.T, pcscCard iso.PCSCCard, secret []byte) []string {
+		require := require.New(t)
+
+		c, err := feitian.NewCard(pcscCard)
+		require.NoError(err)
+
+		err = c.Select()
+		require.NoError(err)
+
+		err = c.Put(feitian.Slot2, "hotp", secret, feitian.SHA1, feitian.HOTP, 6, 0)
+		require.NoError(err)
+
+		err = c.Swap()
+		require.NoError(err)
+
+		otps := []string{}
+
+		for range 3 {
+			code, err := c.Calculate(feitian.Slot1, "hotp")
+			require.NoError(err)
+
+			otps = append(otps, code.OTP())
+		}
+
+		err = c.Close()
+		require.NoError(err)
+
+		return otps
+	}
+
+	r := recorder.New(emulator.New(), "mockdata", t.Name(), "emulator", recorder.WithTestSecrets())
+
+	hotp(t, r, testSecret)
+
+	err := r.Close()
+	require.NoError(t, err)
+
+	t.Run("emulator", func(t *testing.T) {
+		m, err := test.NewMockCard(t, nil)
+		require.NoError(t, err)
+
+		// RFC 4226 Appendix D
+		require.Equal(t, []string{"755224", "287082", "359152"}, hotp(t, m, replacedSecret))
+
+		err = m.Close()
+		require.NoError(t, err)
+	})
+}
+
+func TestRecordTestSecretsAccessCode(t *testing.T) {
+	require := require.New(t)
+
+	r := recorder.New(emulator.New(), t.TempDir(), t.Name(), "emulator", recorder.WithTestSecrets())
+
+	c, err := feitian.NewCard(r)
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	err = c.SetCode("secret")
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	err = c.Validate("secret")
+	require.NoError(err)
+
+	err = c.Close()
+	require.NoError(err)
+
+	var b bytes.Buffer
+
+	err = r.Write(&b)
+	require.NoError(err)
+
+	// The challenge-responses are consistent with the test access code key
+	key := replacedSecret[:16]
+	hmacSHA1 := func(data []byte) []byte {
+		mac := hmac.New(sha1.New, key)
+		mac.Write(data)
+
+		return mac.Sum(nil)
+	}
+
+	values := func(buf []byte) map[tlv.Tag][]byte {
+		tvs, err := tlv.DecodeSimple(buf)
+		require.NoError(err)
+
+		m := map[tlv.Tag][]byte{}
+		for _, tv := range tvs {
+			m[tv.Tag] = tv.Value
+		}
+
+		return m
+	}
+
+	var challenge []byte
+
+	checked := 0
+
+	for _, line := range strings.Split(b.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 6 || fields[3] != "Transmit" {
+			continue
+		}
+
+		cmd, err := hex.DecodeString(fields[4])
+		require.NoError(err)
+
+		resp, err := hex.DecodeString(fields[5])
+		require.NoError(err)
+
+		resp = resp[:len(resp)-2]
+
+		switch cmd[1] {
+		case 0xA4: // Select
+			challenge = values(resp)[0x54]
+
+		case 0x02: // Set code
+			v := values(cmd[5:])
+			require.Equal(append([]byte{0x21}, key...), v[0x53])
+			require.Equal(hmacSHA1(v[0x54]), v[0x55])
+			checked++
+
+		case 0x19: // Validate
+			v := values(cmd[5:])
+			require.Equal(hmacSHA1(challenge), v[0x55])
+			require.Equal(hmacSHA1(v[0x54]), values(resp)[0x55])
+			checked++
+		}
+	}
+
+	require.Equal(2, checked)
+}
+
+// chainingCard returns responses in parts of at most chunkLength
+// bytes which must be retrieved by send remaining commands.
+type chainingCard struct {
+	*emulator.Card
+
+	remaining []byte
+}
+
+const chunkLength = 8
+
+func (c *chainingCard) Transmit(cmd []byte) ([]byte, error) {
+	var data []byte
+
+	if cmd[1] == 0x1B { // Send remaining
+		data = c.remaining
+	} else {
+		resp, err := c.Card.Transmit(cmd)
+		if err != nil || len(resp) <= chunkLength+2 {
+			return resp, err
+		}
+
+		data = resp[:len(resp)-2]
+	}
+
+	if len(data) <= chunkLength {
+		c.remaining = nil
+		return append(data, 0x90, 0x00), nil
+	}
+
+	c.remaining = data[chunkLength:]
+
+	return append(data[:chunkLength:chunkLength], 0x61, byte(min(len(c.remaining), 0xFF))), nil
+}
+
+func (c *chainingCard) Base() iso.PCSCCard {
+	return c
+}
+
+func TestRecordTestSecretsChained(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	r := recorder.New(&chainingCard{Card: emulator.New()}, "mockdata", t.Name(), "emulator", recorder.WithTestSecrets())
+
+	session(t, r)
+
+	err := r.Close()
+	require.NoError(t, err)
+
+	transcript, err := os.ReadFile(r.Path())
+	require.NoError(t, err)
+	require.Contains(t, string(transcript), " 001b")
+
+	// The responses have been reassembled before they have been recalculated
+	t.Run("emulator", func(t *testing.T) {
+		m, err := test.NewMockCard(t, nil)
+		require.NoError(t, err)
+
+		replayedCode, replayedPass := sessionWithSecrets(t, m, replacedSecret, replacedPassword)
+		require.Equal(t, "07081804", replayedCode.OTP()) // RFC 6238 Appendix B
+		require.Equal(t, replacedPassword, replayedPass.Digest)
+
+		err = m.Close()
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	iso "cunicu.li/go-iso7816"
+)
+
+var ErrNotReconnectable = errors.New("card does not support reconnecting")
+
+// Recovery is the action taken to recover from a failed command.
+type Recovery int
+
+const (
+	// RecoverNone returns the error to the caller.
+	RecoverNone Recovery = iota
+
+	// RecoverSelect selects the OTP applet again and retries the command.
+	RecoverSelect
+
+	// RecoverReconnect reconnects to the card and selects the OTP applet
+	// again. Only commands which do not modify the applet are retried.
+	// The card passed to NewCard() must implement iso7816.ReconnectableCard.
+	RecoverReconnect
+)
+
+// RecoveryPolicy decides how to recover from the error of a failed command.
+type RecoveryPolicy func(err error) Recovery
+
+// RecoverNotSelected is a RecoveryPolicy which selects the OTP applet again
+// if a command has been rejected because a different applet is selected.
+// This happens if another application selected the FIDO applet in between.
+func RecoverNotSelected(err error) Recovery {
+	if errors.Is(err, iso.ErrUnsupportedInstruction) || errors.Is(err, iso.ErrUnsupportedClass) {
+		return RecoverSelect
+	}
+
+	return RecoverNone
+}
+
+// recover performs the recovery action rec.
+// The caller must hold c.mu.
+func (c *Card) recover(ctx context.Context, rec Recovery) error {
+	c.recovering = true
+	defer func() { c.recovering = false }()
+
+	if rec == RecoverReconnect {
+		rc, ok := c.Card.Base().(iso.ReconnectableCard)
+		if !ok {
+			return ErrNotReconnectable
+		}
+
+		if err := rc.Reconnect(false); err != nil {
+			return fmt.Errorf("failed to reconnect: %w", err)
+		}
+
+		// The transaction has been terminated together with the connection
+		if c.tx != nil {
+			tx, err := c.Card.NewTransaction()
+			if err != nil {
+				return fmt.Errorf("failed to initiate transaction: %w", err)
+			}
+
+			c.tx = tx
+		}
+	}
+
+	return c.reselect(ctx)
+}
+
+// reselect selects the OTP applet again and unlocks it
+// with the retained access code key.
+// The caller must hold c.mu.
+func (c *Card) reselect(ctx context.Context) error {
+	if err := c.selectApplet(); err != nil {
+		return fmt.Errorf("failed to select applet: %w", err)
+	}
+
+	if c.challenge != nil && c.codeKey != nil {
+		if err := c.validate(ctx, c.codeKey); err != nil {
+			return fmt.Errorf("failed to unlock applet: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// isIdempotent checks if a command can be retried without knowing
+// whether the applet executed it before the connection got lost.
+func isIdempotent(ins iso.Instruction) bool {
+	switch ins {
+	case insList, insGetDefault:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+	"github.com/stretchr/testify/require"
+
+	"cunicu.li/go-feitian-oath"
+	"cunicu.li/go-feitian-oath/emulator"
+)
+
+var errRemoved = errors.New("card removed")
+
+// faultyCard injects the failures of a key which is
+// removed or used by another application.
+type faultyCard struct {
+	*emulator.Card
+
+	fail       []error // Returned by the next calls to Transmit unless nil
+	reconnects int
+}
+
+func (c *faultyCard) Transmit(cmd []byte) ([]byte, error) {
+	if len(c.fail) > 0 {
+		err := c.fail[0]
+		c.fail = c.fail[1:]
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.Card.Transmit(cmd)
+}
+
+func (c *faultyCard) Reconnect(bool) error {
+	c.reconnects++
+
+	// A fresh connection has no applet selected
+	_, err := c.Card.Transmit(selectFIDO)
+
+	return err
+}
+
+func (c *faultyCard) Base() iso.PCSCCard {
+	return c
+}
+
+func recoverRemoved(err error) feitian.Recovery {
+	if errors.Is(err, errRemoved) {
+		return feitian.RecoverReconnect
+	}
+
+	return feitian.RecoverNotSelected(err)
+}
+
+func TestRecoverSelect(t *testing.T) {
+	require := require.New(t)
+
+	e := &faultyCard{Card: emulator.New()}
+
+	c, err := feitian.NewCard(e)
+	require.NoError(err)
+
+	err = c.Select()
+	require.NoError(err)
+
+	err = c.Put(feitian.Slot1, "slot1", testSecretSHA1, feitian.SHA1, feitian.TOTP, 6, 0)
+	require.NoError(err)
+
+	_, err = e.Card.Transmit(selectFIDO)
+	require.NoError(err)
+
+	_, err = c.List()
+	require.ErrorIs(err, feitian.ErrUnsupportedCommand)
+
+	c.Recovery = feitian.RecoverNotSelected
+
+	items, err := c.List()
+	require.NoError(err)
+	require.Len(items, 1)
+	require.Equal(0, e.reconnects)
+}
+
+func TestRecoverReconnect(t *testing.T) {
+	require := require.New(t)
+
+	e := &faultyCard{Card: emulator.New()}
+
+	c, err := feitian.NewCard(e)
+	require.NoError(err)
+
+	c.Recovery = recoverRemoved
+
+	err = c.Select()
+	require.NoError(err)
+
+	err = c.Put(feitian.Slot1, "totp", testSecretSHA1, feitian.SHA1, feitian.TOTP, 8, 0)
+	require.NoError(err)
+
+	c.Clock = func() time.Time { return time.Unix(1111111109, 0) }
+
+	// Commands which do not modify the applet are retried
+	e.fail = []error{errRemoved}
+
+	items, err := c.List()
+	require.NoError(err)
+	require.Len(items, 1)
+	require.Equal(1, e.reconnects)
+
+	// The command is only retried once after the select succeeded
+	e.fail = []error{errRemoved, nil, errRemoved}
+
+	_, err = c.List()
+	require.ErrorIs(err, errRemoved)
+	require.Equal(2, e.reconnects)
+
+	// Other commands are not retried as the applet might have executed them already
+	e.fail = []error{errRemoved}
+
+	err = c.Swap()
+	require.ErrorIs(err, errRemoved)
+	require.Equal(3, e.reconnects)
+
+	items, err = c.List()
+	require.NoError(err)
+	require.Len(items, 1)
+	require.Equal(feitian.Slot1, items[0].Slot)
+
+	e.fail = []error{errRemoved}
+
+	_, err = c.Calculate(feitian.Slot1, "totp")
+	require.ErrorIs(err, errRemoved)
+	require.Equal(4, e.reconnects)
+
+	// The connection has been recovered nevertheless
+	code, err := c.Calculate(feitian.Slot1, "totp")
+	require.NoError(err)
+	require.Equal("07081804", code.OTP())
+
+	// Errors of the applet itself are not recovered
+	_, err = c.Calculate(feitian.Slot2, "totp")
+	require.ErrorIs(err, feitian.ErrNoSuchCredential)
+	require.Equal(4, e.reconnects)
+}
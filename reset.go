@@ -3,12 +3,36 @@
 
 package feitian
 
-import iso "cunicu.li/go-iso7816"
+import (
+	"context"
 
-// Reset deletes all OTP credentials.
+	iso "cunicu.li/go-iso7816"
+)
+
+// Reset deletes all OTP credentials and the access code.
+//
+// It is unknown whether the applet also changes its device ID.
+// Hence, the applet is selected again before the ID is used next by
+// SetCode or the Journal. DeviceInfo is only updated by these or by Select.
 func (c *Card) Reset() error {
-	_, err := c.Send(&iso.CAPDU{
+	return c.ResetContext(context.Background())
+}
+
+// ResetContext is like Reset but honors the cancellation of ctx.
+func (c *Card) ResetContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	_, err := c.send(ctx, &iso.CAPDU{
 		Ins: insReset,
 	})
-	return err
+	if err != nil {
+		return wrapStatus(err)
+	}
+
+	c.codeKey = nil
+	c.challenge = nil
+	c.stale = true
+
+	return c.Journal.reset(c.info.ID)
 }
@@ -3,12 +3,30 @@
 
 package feitian
 
-import "cunicu.li/go-iso7816"
+import (
+	"context"
+
+	"cunicu.li/go-iso7816"
+)
 
 // Swap swaps the two slot configurations.
 func (c *Card) Swap() error {
-	_, err := c.Send(&iso7816.CAPDU{
+	return c.SwapContext(context.Background())
+}
+
+// SwapContext is like Swap but honors the cancellation of ctx.
+func (c *Card) SwapContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	_, err := c.send(ctx, &iso7816.CAPDU{
 		Ins: insSwapSlot,
 	})
-	return err
+	if err != nil {
+		return wrapStatus(err)
+	}
+
+	return c.updateJournal(ctx, func(j *Journal, deviceID string) error {
+		return j.swap(deviceID)
+	})
 }
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"errors"
+
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+// TouchPolicy defines whether the button of the key must be touched
+// before the applet calculates a code of a credential.
+type TouchPolicy byte
+
+const (
+	TouchUnknown  TouchPolicy = iota // The applet did not report the policy
+	TouchNone                        // No touch required
+	TouchRequired                    // A touch is required for every calculation
+)
+
+// Values of the tagTouch TLV in Put and List.
+//
+// touchNone is the value which has always been sent by the vendor tool.
+// touchRequired has not been confirmed by a recorded transcript yet.
+// It is therefore only sent if Card.ExperimentalTouch is set.
+const (
+	touchNone     = applet.TouchNone
+	touchRequired = applet.TouchRequired
+)
+
+var (
+	ErrInvalidTouchPolicy = errors.New("invalid touch policy")
+	ErrExperimentalTouch  = errors.New("touch policy is experimental and must be enabled by Card.ExperimentalTouch")
+)
+
+func (p TouchPolicy) String() string {
+	switch p {
+	case TouchNone:
+		return "none"
+	case TouchRequired:
+		return "required"
+	default:
+		return "unknown"
+	}
+}
+
+func (p TouchPolicy) encode() (byte, error) {
+	switch p {
+	case TouchNone:
+		return touchNone, nil
+	case TouchRequired:
+		return touchRequired, nil
+	default:
+		return 0, ErrInvalidTouchPolicy
+	}
+}
+
+// checkTouch returns ErrExperimentalTouch for TouchRequired
+// unless c.ExperimentalTouch is set. The caller must hold c.mu.
+func (c *Card) checkTouch(p TouchPolicy) error {
+	if p == TouchRequired && !c.ExperimentalTouch {
+		return ErrExperimentalTouch
+	}
+
+	return nil
+}
+
+func decodeTouch(v byte) TouchPolicy {
+	if v == touchRequired {
+		return TouchRequired
+	}
+
+	return TouchNone
+}
@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: 2024-2025 Steffen Vogel <post@steffenvogel.de>
+// SPDX-License-Identifier: Apache-2.0
+
+package feitian
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	iso "cunicu.li/go-iso7816"
+	"cunicu.li/go-iso7816/encoding/tlv"
+
+	"cunicu.li/go-feitian-oath/internal/applet"
+)
+
+// Trace describes a command which has been exchanged with the applet.
+//
+// Responses which are split across multiple APDUs are reported as a whole.
+// Unless Card.TraceSecrets is set, secrets are replaced by zero bytes.
+// These are the keys of Put and SetCode commands, the OTP values and
+// static passwords returned by Calculate and CalculateAll as well as all
+// challenges and responses of the access code authentication. Together with
+// the device ID, the latter would allow to guess the access code offline.
+type Trace struct {
+	Instruction iso.Instruction
+	P1, P2      byte
+	Data        []byte // Command data
+	Response    []byte // Response data
+	Status      iso.Code
+	Duration    time.Duration
+
+	// Err is set if the command could not be exchanged with the card.
+	// Errors reported by the applet are contained in Status.
+	Err error
+}
+
+// Name returns the name of the instruction.
+func (t Trace) Name() string {
+	switch t.Instruction {
+	case iso.InsSelect:
+		return "SELECT"
+	case insPut:
+		return "PUT"
+	case insDelete:
+		return "DELETE"
+	case insSetCode:
+		return "SET CODE"
+	case insReset:
+		return "RESET"
+	case insList:
+		return "LIST"
+	case insCalculate:
+		return "CALCULATE"
+	case insValidate:
+		return "VALIDATE"
+	case insCalculateAll:
+		return "CALCULATE ALL"
+	case insSendRemaining, insSendRemainingFT:
+		return "SEND REMAINING"
+	case insLanguage:
+		return "LANGUAGE"
+	case insApplication:
+		return "APPLICATION"
+	case insSetDefault:
+		return "SET DEFAULT"
+	case insGetDefault:
+		return "GET DEFAULT"
+	case insSwapSlot:
+		return "SWAP SLOT"
+	default:
+		return fmt.Sprintf("0x%02X", byte(t.Instruction))
+	}
+}
+
+// Tracer is called for each command exchanged with the applet.
+type Tracer func(t Trace)
+
+// SlogTracer returns a Tracer which logs the commands to l at debug level.
+func SlogTracer(l *slog.Logger) Tracer {
+	return func(t Trace) {
+		attrs := []any{
+			slog.String("ins", t.Name()),
+			slog.String("p1p2", hex.EncodeToString([]byte{t.P1, t.P2})),
+			slog.String("data", hex.EncodeToString(t.Data)),
+			slog.String("response", hex.EncodeToString(t.Response)),
+			slog.String("sw", hex.EncodeToString(t.Status[:])),
+			slog.Duration("duration", t.Duration),
+		}
+
+		if t.Err != nil {
+			attrs = append(attrs, slog.Any("error", t.Err))
+		}
+
+		l.Debug("APDU", attrs...)
+	}
+}
+
+// transmit sends a command to the card and reports it to c.Tracer.
+// The caller must hold c.mu.
+func (c *Card) transmit(cmd *iso.CAPDU) ([]byte, error) {
+	if c.Tracer == nil {
+		return c.Send(cmd)
+	}
+
+	start := time.Now()
+	resp, err := c.Send(cmd)
+
+	t := Trace{
+		Instruction: cmd.Ins,
+		P1:          cmd.P1,
+		P2:          cmd.P2,
+		Data:        cmd.Data,
+		Response:    resp,
+		Status:      iso.ErrSuccess,
+		Duration:    time.Since(start),
+	}
+
+	if err != nil {
+		if !errors.As(err, &t.Status) {
+			t.Status = iso.Code{}
+			t.Err = err
+		}
+	}
+
+	if !c.TraceSecrets {
+		t.Data, t.Response = redact(cmd.Ins, t.Data, t.Response)
+	}
+
+	c.Tracer(t)
+
+	return resp, err
+}
+
+// redact returns copies of the command and response data
+// with secrets replaced by zero bytes.
+func redact(ins iso.Instruction, data, resp []byte) ([]byte, []byte) {
+	switch ins {
+	case iso.InsSelect:
+		// The challenge of a protected applet
+		resp = redactValues(resp, 0, tagChallenge)
+
+	case insPut:
+		// The length of the key is off by one, see PutCredential().
+		// Its value starts with the algorithm, kind and number of digits.
+		data = slices.Clone(data)
+		if len(data) >= 2 && tlv.Tag(data[0]) == tagKey {
+			zero(data, 5, 2+int(data[1])+1)
+		}
+
+	case insSetCode:
+		// The value of the key starts with its type
+		data = redactValues(data, 1, tagKey)
+		data = redactValues(data, 0, tagChallenge, tagResponse)
+
+	case insValidate:
+		data = redactValues(data, 0, tagChallenge, tagResponse)
+		resp = redactValues(resp, 0, tagResponse)
+
+	case insCalculate, insCalculateAll:
+		// The value of responses starts with the number of digits
+		resp = redactValues(resp, 1, tagResponse, tagTResponse)
+	}
+
+	return data, resp
+}
+
+// redactValues returns a copy of the SIMPLE-TLV encoded data
+// with the values of the given tags replaced by zero bytes.
+// The first skip bytes of each value are retained.
+// Malformed data is replaced entirely.
+func redactValues(data []byte, skip int, tags ...tlv.Tag) []byte {
+	data = slices.Clone(data)
+
+	vs, err := applet.Values(data, tags...)
+	if err != nil {
+		zero(data, 0, len(data))
+		return data
+	}
+
+	for _, v := range vs {
+		zero(v, skip, len(v))
+	}
+
+	return data
+}
+
+func zero(b []byte, from, to int) {
+	to = min(to, len(b))
+	for i := from; i < to; i++ {
+		b[i] = 0
+	}
+}